@@ -10,14 +10,14 @@ import (
 // Ref: http://www.questrade.com/api/documentation/rest-operations/account-calls/accounts
 type Account struct {
 	// Type of the account (e.g., "Cash", "Margin").
-	Type string `json:"type"`
+	Type AccountType `json:"type"`
 
 	// Eight-digit account number (e.g., "26598145")
 	// Stored as a string, it's used for making account-related API calls
 	Number string `json:"number"`
 
 	// Status of the account (e.g., Active).
-	Status string `json:"status"`
+	Status AccountStatus `json:"status"`
 
 	// Whether this is a primary account for the holder.
 	IsPrimary bool `json:"isPrimary"`
@@ -26,7 +26,7 @@ type Account struct {
 	IsBilling bool `json:"isBilling"`
 
 	// Type of client holding the account (e.g., "Individual").
-	ClientAccountType string `json:"clientAccountType"`
+	ClientAccountType ClientAccountType `json:"clientAccountType"`
 }
 
 // Position belonging to an account
@@ -46,22 +46,22 @@ type Position struct {
 	ClosedQuantity float32 `json:"closedQuantity"`
 
 	// Market value of the position (quantity x price).
-	CurrentMarketValue float32 `json:"currentMarketValue"`
+	CurrentMarketValue Money `json:"currentMarketValue"`
 
 	// Current price of the position symbol.
-	CurrentPrice float32 `json:"currentPrice"`
+	CurrentPrice Money `json:"currentPrice"`
 
 	// Average price paid for all executions constituting the position.
-	AverageEntryPrice float32 `json:"averageEntryPrice"`
+	AverageEntryPrice Money `json:"averageEntryPrice"`
 
 	// Realized profit/loss on this position.
-	ClosedPnL float32 `json:"closedPnL"`
+	ClosedPnL Money `json:"closedPnL"`
 
 	// Unrealized profit/loss on this position.
-	OpenPnL float32 `json:"openPnL"`
+	OpenPnL Money `json:"openPnL"`
 
 	// Total cost of the position.
-	TotalCost float32 `json:"totalCost"`
+	TotalCost Money `json:"totalCost"`
 
 	// Designates whether real-time quote was used to compute PnL.
 	IsRealTime bool `json:"isRealTime"`
@@ -76,22 +76,22 @@ type Position struct {
 type Balance struct {
 
 	// Currency of the balance figure(e.g., "USD" or "CAD").
-	Currency string `json:"currency"`
+	Currency Currency `json:"currency"`
 
 	// Balance amount.
-	Cash float32 `json:"cash"`
+	Cash Money `json:"cash"`
 
 	// Market value of all securities in the account in a given currency.
-	MarketValue float32 `json:"marketValue"`
+	MarketValue Money `json:"marketValue"`
 
 	// Equity as a difference between cash and marketValue properties.
-	TotalEquity float32 `json:"totalEquity"`
+	TotalEquity Money `json:"totalEquity"`
 
 	// Buying power for that particular currency side of the account.
-	BuyingPower float32 `json:"buyingPower"`
+	BuyingPower Money `json:"buyingPower"`
 
 	// Maintenance excess for that particular side of the account.
-	MaintenanceExcess float32 `json:"maintenanceExcess"`
+	MaintenanceExcess Money `json:"maintenanceExcess"`
 
 	// Whether real-time data was used to calculate the above values.
 	IsRealTime bool `json:"isRealTime"`
@@ -121,10 +121,10 @@ type Execution struct {
 	Quantity int `json:"quantity"`
 
 	// Client side of the order to which execution belongs.
-	Side string `json:"side"`
+	Side OrderSide `json:"side"`
 
 	// Execution price.
-	Price float32 `json:"price"`
+	Price Money `json:"price"`
 
 	// Internal identifier of the execution.
 	ID int `json:"id"`
@@ -148,23 +148,71 @@ type Execution struct {
 	Venue string `json:"venue"`
 
 	// Execution cost (price x quantity).
-	TotalCost float32 `json:"totalCost"`
+	TotalCost Money `json:"totalCost"`
 
 	// Questrade commission for orders placed with Trade Desk.
-	OrderPlacementCommission float32 `json:"orderPlacementCommission"`
+	OrderPlacementCommission Money `json:"orderPlacementCommission"`
 
 	// Questrade commission.
-	Commission float32 `json:"commission"`
+	Commission Money `json:"commission"`
 
 	// Liquidity fee charged by execution venue.
-	ExecutionFee float32 `json:"executionFee"`
+	ExecutionFee Money `json:"executionFee"`
 
 	// SEC fee charged on all sales of US securities.
-	SecFee float32 `json:"secFee"`
+	SecFee Money `json:"secFee"`
 
 	// Additional execution fee charged by TSX (if applicable).
-	CanadianExecutionFee int `json:"canadianExecutionFee"`
+	CanadianExecutionFee Money `json:"canadianExecutionFee"`
 
 	// Internal identifierof the parent order.
 	ParentID int `json:"parentId"`
 }
+
+// Activity represents an account activity, such as a trade, dividend, deposit,
+// fee or transfer.
+//
+// Ref: http://www.questrade.com/api/documentation/rest-operations/account-calls/accounts-id-activities
+type Activity struct {
+	// Trade date.
+	TradeDate time.Time `json:"tradeDate"`
+
+	// Date of the transaction.
+	TransactionDate time.Time `json:"transactionDate"`
+
+	// Date the trade was settled.
+	SettlementDate time.Time `json:"settlementDate"`
+
+	// Activity action (e.g., "Buy").
+	Action string `json:"action"`
+
+	// Symbol name.
+	Symbol string `json:"symbol"`
+
+	// Internal symbol identifier.
+	SymbolID int `json:"symbolId"`
+
+	// Description of the activity.
+	Description string `json:"description"`
+
+	// Currency of the activity (e.g., "USD" or "CAD").
+	Currency Currency `json:"currency"`
+
+	// The quantity.
+	Quantity float32 `json:"quantity"`
+
+	// The price.
+	Price Money `json:"price"`
+
+	// Gross amount.
+	GrossAmount Money `json:"grossAmount"`
+
+	// The commission.
+	Commission Money `json:"commission"`
+
+	// Net amount.
+	NetAmount Money `json:"netAmount"`
+
+	// Activity type (e.g., "Trades", "Dividends").
+	Type ActivityType `json:"type"`
+}
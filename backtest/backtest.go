@@ -0,0 +1,198 @@
+// Package backtest replays historical candlesticks through a trading strategy.
+//
+// The strategy is called with every candlestick in time order, and trades through
+// a qapi.Trader backed by the paper trading simulator in the sim package. Orders
+// it places are filled against the candlesticks that follow, with the slippage
+// and commission schedule of the backtest. The result holds the equity curve,
+// the executions and summary statistics:
+//
+//	candles, err := client.GetCandles(symId, start, end, qapi.IntervalOneDay)
+//
+//	res, err := backtest.Run(ctx, []backtest.Series{{SymbolID: symId, Symbol: "AAPL", Candles: candles}},
+//		func(ctx context.Context, t qapi.Trader, bar backtest.Bar) error {
+//			// Place orders with AccountID: backtest.Account
+//			return nil
+//		},
+//		backtest.WithCommission(backtest.QuestradeStocks))
+//
+//	fmt.Printf("CAGR: %.2f%% Max drawdown: %.2f%%\n", res.Stats.CAGR*100, res.Stats.MaxDrawdown*100)
+package backtest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/sim"
+)
+
+// Account is the number of the simulated account that strategies trade in.
+const Account = "BACKTEST"
+
+// Series is the candlestick history of a symbol.
+type Series struct {
+	SymbolID int
+	Symbol   string
+	Candles  []qapi.Candlestick
+}
+
+// Bar is a candlestick of one of the replayed symbols.
+type Bar struct {
+	SymbolID int
+	Symbol   string
+	qapi.Candlestick
+}
+
+// Strategy is called with every bar of the backtest, in order of the end of the
+// bars, after orders have been filled against it. Orders placed by the strategy
+// are filled against the following bars of their symbol. Returning an error stops
+// the backtest.
+type Strategy func(ctx context.Context, t qapi.Trader, bar Bar) error
+
+// Option customizes a backtest.
+type Option func(*config)
+
+// config holds the settings of a backtest
+type config struct {
+	currency     qapi.Currency
+	cash         qapi.Money
+	commission   sim.CommissionFunc
+	slippage     sim.SlippageFunc
+	riskFreeRate float64
+}
+
+// WithCash sets the starting cash balance of the account. By default it is
+// $100,000 USD.
+func WithCash(currency qapi.Currency, cash qapi.Money) Option {
+	return func(c *config) {
+		c.currency = currency
+		c.cash = cash
+	}
+}
+
+// WithCommission sets the commission schedule, such as QuestradeStocks. By
+// default no commission is charged.
+func WithCommission(f sim.CommissionFunc) Option {
+	return func(c *config) {
+		c.commission = f
+	}
+}
+
+// WithSlippage sets the slippage applied to fills, such as FixedSlippage. By
+// default orders are filled at the prices of the bars.
+func WithSlippage(f sim.SlippageFunc) Option {
+	return func(c *config) {
+		c.slippage = f
+	}
+}
+
+// WithRiskFreeRate sets the annual risk-free rate used to calculate the Sharpe
+// ratio (e.g., 0.02 for 2%). By default it is zero.
+func WithRiskFreeRate(rate float64) Option {
+	return func(c *config) {
+		c.riskFreeRate = rate
+	}
+}
+
+// EquityPoint is the total equity of the account at a point in time.
+type EquityPoint struct {
+	Time   time.Time
+	Equity qapi.Money
+}
+
+// Result is the outcome of a backtest.
+type Result struct {
+	// Equity of the account at the start, and after every distinct bar end time.
+	Equity []EquityPoint
+
+	// Executions of the strategy's orders, in order.
+	Trades []qapi.Execution
+
+	// Every order placed by the strategy.
+	Orders []qapi.Order
+
+	// Positions at the end of the backtest, including closed positions.
+	Positions []qapi.Position
+
+	// Summary statistics.
+	Stats Stats
+}
+
+// Run replays the candlesticks of the series through a strategy. If the context is
+// canceled, the backtest stops and the context's error is returned.
+func Run(ctx context.Context, series []Series, strategy Strategy, opts ...Option) (Result, error) {
+	cfg := config{
+		currency: qapi.USD,
+		cash:     qapi.MoneyFromInt(100000),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	bars := merge(series)
+	if len(bars) == 0 {
+		return Result{}, errors.New("Error: No candlesticks to replay")
+	}
+
+	s := sim.New(sim.WithCommission(cfg.commission), sim.WithSlippage(cfg.slippage))
+	s.AddAccount(Account, cfg.currency, cfg.cash)
+	for _, ser := range series {
+		s.AddSymbol(ser.SymbolID, ser.Symbol)
+	}
+
+	res := Result{
+		Equity: []EquityPoint{{bars[0].Start, cfg.cash}},
+	}
+
+	for k, bar := range bars {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		s.Candle(bar.SymbolID, bar.Candlestick)
+		if err := strategy(ctx, s, bar); err != nil {
+			return Result{}, err
+		}
+
+		// Equity is recorded once every symbol has a bar ending at this time
+		if k == len(bars)-1 || !bars[k+1].End.Equal(bar.End) {
+			b, err := s.GetBalancesContext(ctx, Account)
+			if err != nil {
+				return Result{}, err
+			}
+			res.Equity = append(res.Equity, EquityPoint{bar.End, b.CombinedBalances[0].TotalEquity})
+		}
+	}
+
+	var err error
+	if res.Trades, err = s.GetExecutionsContext(ctx, Account, time.Time{}, time.Time{}); err != nil {
+		return Result{}, err
+	}
+	if res.Orders, err = s.GetOrdersContext(ctx, Account, time.Time{}, time.Time{}, qapi.OrderFilterAll); err != nil {
+		return Result{}, err
+	}
+	if res.Positions, err = s.GetPositionsContext(ctx, Account); err != nil {
+		return Result{}, err
+	}
+
+	res.Stats = summarize(res.Equity, res.Trades, cfg.riskFreeRate)
+	return res, nil
+}
+
+// merge combines the candlesticks of the series into one list of bars, sorted by
+// end time. Bars that end at the same time keep the order of the series.
+func merge(series []Series) []Bar {
+	bars := []Bar{}
+	for _, ser := range series {
+		for _, c := range ser.Candles {
+			bars = append(bars, Bar{ser.SymbolID, ser.Symbol, c})
+		}
+	}
+
+	sort.SliceStable(bars, func(i, j int) bool {
+		return bars[i].End.Before(bars[j].End)
+	})
+	return bars
+}
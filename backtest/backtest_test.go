@@ -0,0 +1,160 @@
+package backtest_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/backtest"
+)
+
+var est = time.FixedZone("EST", -5*60*60)
+
+// daily returns daily candlesticks starting on January 6, 2020, stamped from
+// midnight to the following midnight like the Questrade API stamps them. Each
+// candle is given as open, high, low and close prices.
+func daily(prices ...[4]string) []qapi.Candlestick {
+	candles := make([]qapi.Candlestick, len(prices))
+	for k, p := range prices {
+		start := time.Date(2020, 1, 6+k, 0, 0, 0, 0, est)
+		candles[k] = qapi.Candlestick{
+			Start: start,
+			End:   start.AddDate(0, 0, 1),
+			Open:  qapi.MustParseMoney(p[0]),
+			High:  qapi.MustParseMoney(p[1]),
+			Low:   qapi.MustParseMoney(p[2]),
+			Close: qapi.MustParseMoney(p[3]),
+		}
+	}
+	return candles
+}
+
+// marketOrder places a Day market order for 100 shares of a symbol
+func marketOrder(ctx context.Context, t qapi.Trader, id int, side qapi.OrderSide) error {
+	_, err := t.PlaceOrderContext(ctx, qapi.OrderRequest{
+		AccountID:   backtest.Account,
+		SymbolID:    id,
+		Quantity:    100,
+		Action:      side,
+		OrderType:   qapi.OrderTypeMarket,
+		TimeInForce: qapi.TimeInForceDay,
+	})
+	return err
+}
+
+func TestRun(t *testing.T) {
+	series := []backtest.Series{{SymbolID: 8049, Symbol: "AAPL", Candles: daily(
+		[4]string{"10", "10", "10", "10"},
+		[4]string{"11", "12", "10.50", "12"},
+		[4]string{"12", "12", "12", "12"},
+		[4]string{"13", "13.50", "12.50", "13"},
+	)}}
+
+	// Buy after the first day and sell after the third
+	n := 0
+	res, err := backtest.Run(context.Background(), series,
+		func(ctx context.Context, t qapi.Trader, bar backtest.Bar) error {
+			n++
+			switch n {
+			case 1:
+				return marketOrder(ctx, t, bar.SymbolID, qapi.SideBuy)
+			case 3:
+				return marketOrder(ctx, t, bar.SymbolID, qapi.SideSell)
+			}
+			return nil
+		},
+		backtest.WithCash(qapi.CAD, qapi.MustParseMoney("10000")),
+		backtest.WithCommission(backtest.QuestradeStocks),
+		backtest.WithSlippage(backtest.FixedSlippage(qapi.MustParseMoney("0.01"))))
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	// The buy is filled at the second day's open plus slippage, 11.01, and the
+	// sell at the fourth day's open less slippage, 12.99. Each is charged $4.95.
+	if len(res.Trades) != 2 || res.Trades[0].Price.String() != "11.01" || res.Trades[1].Price.String() != "12.99" {
+		t.Fatalf("trades = %+v, want a buy at 11.01 and a sell at 12.99", res.Trades)
+	}
+	if len(res.Orders) != 2 || res.Orders[0].State != qapi.OrderStateExecuted || res.Orders[1].State != qapi.OrderStateExecuted {
+		t.Errorf("orders = %+v, want two executed orders", res.Orders)
+	}
+	if len(res.Positions) != 1 || res.Positions[0].OpenQuantity != 0 || res.Positions[0].Symbol != "AAPL" {
+		t.Errorf("positions = %+v, want a closed AAPL position", res.Positions)
+	}
+
+	// 10,000 - 1,101 - 4.95 = 8,894.05 in cash after the buy, with 1,200 of shares
+	// marked at the close, and 8,894.05 + 1,299 - 4.95 = 10,188.10 after the sell
+	want := []string{"10000.00", "10000.00", "10094.05", "10094.05", "10188.10"}
+	var got []string
+	for _, p := range res.Equity {
+		got = append(got, p.Equity.String())
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("equity = %v, want %v", got, want)
+	}
+	if !res.Equity[0].Time.Equal(series[0].Candles[0].Start) || !res.Equity[4].Time.Equal(series[0].Candles[3].End) {
+		t.Errorf("equity from %v to %v, want from the first start to the last end", res.Equity[0].Time, res.Equity[4].Time)
+	}
+
+	if res.Stats.Trades != 2 || res.Stats.Commission.String() != "9.90" || res.Stats.EndEquity.String() != "10188.10" {
+		t.Errorf("stats = %+v, want 2 trades with 9.90 commission ending at 10188.10", res.Stats)
+	}
+}
+
+func TestRunMergesSeries(t *testing.T) {
+	prices := [][4]string{{"10", "10", "10", "10"}, {"10", "10", "10", "10"}}
+	series := []backtest.Series{
+		{SymbolID: 1, Symbol: "A", Candles: daily(prices...)},
+		{SymbolID: 2, Symbol: "B", Candles: daily(prices...)},
+	}
+
+	// Bars ending at the same time keep the order of the series
+	var bars []string
+	res, err := backtest.Run(context.Background(), series, func(ctx context.Context, t qapi.Trader, bar backtest.Bar) error {
+		bars = append(bars, fmt.Sprintf("%s %d", bar.Symbol, bar.Start.Day()))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if want := "[A 6 B 6 A 7 B 7]"; fmt.Sprint(bars) != want {
+		t.Errorf("bars = %v, want %s", bars, want)
+	}
+
+	// Equity is recorded at the start, and once for each end time
+	if len(res.Equity) != 3 || res.Equity[2].Equity.String() != "100000.00" {
+		t.Errorf("equity = %+v, want three points of the default 100000.00", res.Equity)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	series := []backtest.Series{{SymbolID: 1, Symbol: "A", Candles: daily(
+		[4]string{"10", "10", "10", "10"},
+		[4]string{"10", "10", "10", "10"},
+	)}}
+	none := func(ctx context.Context, t qapi.Trader, bar backtest.Bar) error { return nil }
+
+	if _, err := backtest.Run(context.Background(), []backtest.Series{{SymbolID: 1}}, none); err == nil {
+		t.Error("Run without candlesticks: expected an error")
+	}
+
+	// The strategy's error stops the backtest
+	stop := errors.New("stop")
+	n := 0
+	_, err := backtest.Run(context.Background(), series, func(ctx context.Context, t qapi.Trader, bar backtest.Bar) error {
+		n++
+		return stop
+	})
+	if err != stop || n != 1 {
+		t.Errorf("Run = %v after %d bars, want the strategy's error after 1", err, n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := backtest.Run(ctx, series, none); err != context.Canceled {
+		t.Errorf("Run with a canceled context = %v, want %v", err, context.Canceled)
+	}
+}
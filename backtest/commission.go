@@ -0,0 +1,84 @@
+package backtest
+
+import (
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/sim"
+)
+
+var (
+	cent = qapi.MustParseMoney("0.01")
+
+	stockMin = qapi.MustParseMoney("4.95")
+	stockMax = qapi.MustParseMoney("9.95")
+
+	optionBase     = qapi.MustParseMoney("9.95")
+	optionContract = qapi.MustParseMoney("1.00")
+)
+
+// NoCommission doesn't charge a commission.
+func NoCommission(e qapi.Execution) qapi.Money {
+	return qapi.Money{}
+}
+
+// QuestradeStocks is Questrade's standard commission schedule for stocks: one cent
+// per share, with a minimum of $4.95 and a maximum of $9.95 per trade. ECN fees
+// are not included.
+func QuestradeStocks(e qapi.Execution) qapi.Money {
+	return PerShare(cent, stockMin, stockMax)(e)
+}
+
+// QuestradeETFs is Questrade's commission schedule for ETFs: buying is free, and
+// selling is charged the same as a stock.
+func QuestradeETFs(e qapi.Execution) qapi.Money {
+	if e.Side.IsBuy() {
+		return qapi.Money{}
+	}
+	return QuestradeStocks(e)
+}
+
+// QuestradeOptions is Questrade's standard commission schedule for options: $9.95
+// per trade plus $1 per contract.
+func QuestradeOptions(e qapi.Execution) qapi.Money {
+	return optionBase.Add(optionContract.MulInt(int64(e.Quantity)))
+}
+
+// FlatCommission charges the same commission for every trade.
+func FlatCommission(amount qapi.Money) sim.CommissionFunc {
+	return func(e qapi.Execution) qapi.Money {
+		return amount
+	}
+}
+
+// PerShare charges a commission for every share traded, limited to between a
+// minimum and maximum per trade. A zero maximum is unlimited.
+func PerShare(rate qapi.Money, min qapi.Money, max qapi.Money) sim.CommissionFunc {
+	return func(e qapi.Execution) qapi.Money {
+		c := rate.MulInt(int64(e.Quantity))
+		if c.Cmp(min) < 0 {
+			c = min
+		}
+		if !max.IsZero() && c.Cmp(max) > 0 {
+			c = max
+		}
+		return c
+	}
+}
+
+// FixedSlippage fills orders a fixed amount away from the price of the bar, in
+// the direction that is worse for the order.
+func FixedSlippage(amount qapi.Money) sim.SlippageFunc {
+	return func(side qapi.OrderSide, price qapi.Money) qapi.Money {
+		if side.IsBuy() {
+			return price.Add(amount)
+		}
+		return price.Sub(amount)
+	}
+}
+
+// ProportionalSlippage fills orders a fraction of the price away from the price of
+// the bar (e.g., 0.001 for 0.1%), in the direction that is worse for the order.
+func ProportionalSlippage(fraction float64) sim.SlippageFunc {
+	return func(side qapi.OrderSide, price qapi.Money) qapi.Money {
+		return FixedSlippage(price.MulFloat(fraction))(side, price)
+	}
+}
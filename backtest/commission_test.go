@@ -0,0 +1,73 @@
+package backtest_test
+
+import (
+	"testing"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/backtest"
+	"github.com/alexurquhart/qapi/sim"
+)
+
+// execution returns an execution of a quantity of shares
+func execution(side qapi.OrderSide, qty int) qapi.Execution {
+	return qapi.Execution{Side: side, Quantity: qty, Price: qapi.MustParseMoney("10")}
+}
+
+func TestCommission(t *testing.T) {
+	tests := []struct {
+		name string
+		f    sim.CommissionFunc
+		e    qapi.Execution
+		want string
+	}{
+		{"none", backtest.NoCommission, execution(qapi.SideBuy, 1000), "0.00"},
+		{"flat", backtest.FlatCommission(qapi.MustParseMoney("6.95")), execution(qapi.SideSell, 1), "6.95"},
+
+		{"stocks below the minimum", backtest.QuestradeStocks, execution(qapi.SideBuy, 100), "4.95"},
+		{"stocks at the minimum", backtest.QuestradeStocks, execution(qapi.SideBuy, 495), "4.95"},
+		{"stocks between the limits", backtest.QuestradeStocks, execution(qapi.SideSell, 700), "7.00"},
+		{"stocks at the maximum", backtest.QuestradeStocks, execution(qapi.SideBuy, 995), "9.95"},
+		{"stocks above the maximum", backtest.QuestradeStocks, execution(qapi.SideSell, 5000), "9.95"},
+
+		{"ETF buy", backtest.QuestradeETFs, execution(qapi.SideBuy, 700), "0.00"},
+		{"ETF buy to cover", backtest.QuestradeETFs, execution(qapi.SideBTC, 700), "0.00"},
+		{"ETF sell", backtest.QuestradeETFs, execution(qapi.SideSell, 700), "7.00"},
+
+		{"options", backtest.QuestradeOptions, execution(qapi.SideBTO, 10), "19.95"},
+
+		{"per share without a maximum", backtest.PerShare(qapi.MustParseMoney("0.005"), qapi.MustParseMoney("1"), qapi.Money{}), execution(qapi.SideBuy, 10000), "50.00"},
+		{"per share below the minimum", backtest.PerShare(qapi.MustParseMoney("0.005"), qapi.MustParseMoney("1"), qapi.Money{}), execution(qapi.SideBuy, 100), "1.00"},
+		{"per share without a minimum", backtest.PerShare(qapi.MustParseMoney("0.005"), qapi.Money{}, qapi.MustParseMoney("20")), execution(qapi.SideBuy, 10), "0.05"},
+		{"per share above the maximum", backtest.PerShare(qapi.MustParseMoney("0.005"), qapi.Money{}, qapi.MustParseMoney("20")), execution(qapi.SideBuy, 10000), "20.00"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.f(tt.e); got.String() != qapi.MustParseMoney(tt.want).String() {
+			t.Errorf("%s: commission = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSlippage(t *testing.T) {
+	tests := []struct {
+		name  string
+		f     sim.SlippageFunc
+		side  qapi.OrderSide
+		price string
+		want  string
+	}{
+		{"fixed buy", backtest.FixedSlippage(qapi.MustParseMoney("0.01")), qapi.SideBuy, "10", "10.01"},
+		{"fixed buy to cover", backtest.FixedSlippage(qapi.MustParseMoney("0.01")), qapi.SideBTC, "10", "10.01"},
+		{"fixed sell", backtest.FixedSlippage(qapi.MustParseMoney("0.01")), qapi.SideSell, "10", "9.99"},
+		{"fixed short", backtest.FixedSlippage(qapi.MustParseMoney("0.01")), qapi.SideShort, "10", "9.99"},
+		{"proportional buy", backtest.ProportionalSlippage(0.001), qapi.SideBuy, "50", "50.05"},
+		{"proportional sell", backtest.ProportionalSlippage(0.001), qapi.SideSell, "50", "49.95"},
+		{"proportional sub-cent", backtest.ProportionalSlippage(0.001), qapi.SideBuy, "1.23", "1.23123"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.f(tt.side, qapi.MustParseMoney(tt.price)); got.String() != qapi.MustParseMoney(tt.want).String() {
+			t.Errorf("%s: price = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
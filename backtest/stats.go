@@ -0,0 +1,128 @@
+package backtest
+
+import (
+	"math"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// year is the average length of a calendar year
+const year = 365.25 * 24 * time.Hour
+
+// Stats summarizes the performance of a backtest.
+type Stats struct {
+	// Equity at the start and end of the backtest.
+	StartEquity qapi.Money
+	EndEquity   qapi.Money
+
+	// Return over the whole backtest (e.g., 0.25 for 25%).
+	TotalReturn float64
+
+	// Compound annual growth rate.
+	CAGR float64
+
+	// Largest fall in equity from a previous peak, as a fraction of the peak.
+	MaxDrawdown float64
+
+	// Longest time taken for equity to recover to a previous peak. A drawdown that
+	// hasn't recovered by the end of the backtest counts until the end.
+	MaxDrawdownDuration time.Duration
+
+	// Annualized Sharpe ratio of the returns between equity points. The number of
+	// periods in a year is taken from the equity curve, so bars outside of market
+	// hours don't need to be accounted for.
+	Sharpe float64
+
+	// Number of executions, and the total commission they were charged.
+	Trades     int
+	Commission qapi.Money
+}
+
+// summarize calculates the statistics of an equity curve
+func summarize(equity []EquityPoint, trades []qapi.Execution, riskFreeRate float64) Stats {
+	first, last := equity[0], equity[len(equity)-1]
+	st := Stats{
+		StartEquity: first.Equity,
+		EndEquity:   last.Equity,
+		Trades:      len(trades),
+	}
+
+	for _, t := range trades {
+		st.Commission = st.Commission.Add(t.Commission)
+	}
+
+	start, end := first.Equity.Float64(), last.Equity.Float64()
+	if start <= 0 {
+		return st
+	}
+	st.TotalReturn = end/start - 1
+
+	years := float64(last.Time.Sub(first.Time)) / float64(year)
+	if years > 0 && end > 0 {
+		st.CAGR = math.Pow(end/start, 1/years) - 1
+	}
+
+	st.MaxDrawdown, st.MaxDrawdownDuration = drawdown(equity)
+	if years > 0 {
+		st.Sharpe = sharpe(equity, riskFreeRate, float64(len(equity)-1)/years)
+	}
+	return st
+}
+
+// drawdown returns the largest drawdown of an equity curve, and the longest time
+// spent below a previous peak
+func drawdown(equity []EquityPoint) (float64, time.Duration) {
+	var maxDD float64
+	var maxDur time.Duration
+
+	peak, peakTime := equity[0].Equity.Float64(), equity[0].Time
+	for _, p := range equity {
+		e := p.Equity.Float64()
+		if e >= peak {
+			peak, peakTime = e, p.Time
+			continue
+		}
+
+		if peak > 0 {
+			maxDD = math.Max(maxDD, (peak-e)/peak)
+		}
+		if d := p.Time.Sub(peakTime); d > maxDur {
+			maxDur = d
+		}
+	}
+	return maxDD, maxDur
+}
+
+// sharpe returns the annualized Sharpe ratio of the returns between equity points
+func sharpe(equity []EquityPoint, riskFreeRate float64, periodsPerYear float64) float64 {
+	if len(equity) < 3 || periodsPerYear <= 0 {
+		return 0
+	}
+
+	rf := math.Pow(1+riskFreeRate, 1/periodsPerYear) - 1
+	excess := make([]float64, 0, len(equity)-1)
+	for k := 1; k < len(equity); k++ {
+		prev := equity[k-1].Equity.Float64()
+		if prev <= 0 {
+			return 0
+		}
+		excess = append(excess, equity[k].Equity.Float64()/prev-1-rf)
+	}
+
+	var mean float64
+	for _, r := range excess {
+		mean += r
+	}
+	mean /= float64(len(excess))
+
+	var variance float64
+	for _, r := range excess {
+		variance += (r - mean) * (r - mean)
+	}
+	sd := math.Sqrt(variance / float64(len(excess)-1))
+	if sd == 0 {
+		return 0
+	}
+	return mean / sd * math.Sqrt(periodsPerYear)
+}
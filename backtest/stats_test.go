@@ -0,0 +1,99 @@
+package backtest
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// curve returns an equity curve over exactly one year, with evenly spaced points
+func curve(equity ...string) []EquityPoint {
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	step := year / time.Duration(len(equity)-1)
+
+	points := make([]EquityPoint, len(equity))
+	for k, e := range equity {
+		points[k] = EquityPoint{start.Add(time.Duration(k) * step), qapi.MustParseMoney(e)}
+	}
+	return points
+}
+
+// near determines whether two floats are equal to within rounding error
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSummarize(t *testing.T) {
+	equity := curve("100000", "110000", "99000", "121000")
+	trades := []qapi.Execution{
+		{Commission: qapi.MustParseMoney("4.95")},
+		{Commission: qapi.MustParseMoney("9.95")},
+	}
+	st := summarize(equity, trades, 0)
+
+	if st.StartEquity.String() != "100000.00" || st.EndEquity.String() != "121000.00" {
+		t.Errorf("start and end equity = %s and %s, want 100000.00 and 121000.00", st.StartEquity, st.EndEquity)
+	}
+	if st.Trades != 2 || st.Commission.String() != "14.90" {
+		t.Errorf("trades = %d with %s commission, want 2 with 14.90", st.Trades, st.Commission)
+	}
+
+	// Over exactly one year, the CAGR is the total return
+	if !near(st.TotalReturn, 0.21) || !near(st.CAGR, 0.21) {
+		t.Errorf("total return and CAGR = %v and %v, want 0.21", st.TotalReturn, st.CAGR)
+	}
+
+	// From the 110,000 peak down to 99,000, which is below the peak for a third
+	// of the year
+	if !near(st.MaxDrawdown, 0.1) || st.MaxDrawdownDuration != year/3 {
+		t.Errorf("max drawdown = %v over %v, want 0.1 over %v", st.MaxDrawdown, st.MaxDrawdownDuration, year/3)
+	}
+
+	// The returns are 1/10, -1/10 and 2/9, with a mean of 2/27 and a sample
+	// standard deviation of sqrt(1929)/270. With three periods a year, the
+	// Sharpe ratio is 2/27 / (sqrt(1929)/270) * sqrt(3) = 20 * sqrt(3/1929).
+	if want := 0.788724; math.Abs(st.Sharpe-want) > 1e-6 {
+		t.Errorf("Sharpe = %v, want %v", st.Sharpe, want)
+	}
+}
+
+func TestSummarizeRiskFreeRate(t *testing.T) {
+	// A 33.1% annual rate is 10% for each of the three periods, which lowers the
+	// mean return to -7/270 without changing the standard deviation
+	st := summarize(curve("100000", "110000", "99000", "121000"), nil, 0.331)
+	if want := -0.276054; math.Abs(st.Sharpe-want) > 1e-6 {
+		t.Errorf("Sharpe = %v, want %v", st.Sharpe, want)
+	}
+}
+
+func TestSummarizeCAGR(t *testing.T) {
+	// Doubling over two years is sqrt(2) - 1 a year
+	equity := curve("50000", "100000")
+	equity[1].Time = equity[0].Time.Add(2 * year)
+	if st := summarize(equity, nil, 0); !near(st.CAGR, math.Sqrt2-1) || !near(st.TotalReturn, 1) {
+		t.Errorf("CAGR = %v with total return %v, want %v and 1", st.CAGR, st.TotalReturn, math.Sqrt2-1)
+	}
+}
+
+func TestSummarizeUnrecoveredDrawdown(t *testing.T) {
+	// The drawdown from 120,000 lasts until the end, and the deepest point is 90,000
+	st := summarize(curve("100000", "120000", "90000", "96000", "108000"), nil, 0)
+	if !near(st.MaxDrawdown, 0.25) || st.MaxDrawdownDuration != year*3/4 {
+		t.Errorf("max drawdown = %v over %v, want 0.25 over %v", st.MaxDrawdown, st.MaxDrawdownDuration, year*3/4)
+	}
+}
+
+func TestSummarizeFlatEquity(t *testing.T) {
+	st := summarize(curve("100000", "100000", "100000"), nil, 0)
+	if st.TotalReturn != 0 || st.CAGR != 0 || st.MaxDrawdown != 0 || st.MaxDrawdownDuration != 0 || st.Sharpe != 0 {
+		t.Errorf("stats of a flat equity curve = %+v, want zeros", st)
+	}
+
+	// A single point has no returns to speak of
+	st = summarize(curve("100000", "100000")[:1], nil, 0)
+	if st.TotalReturn != 0 || st.CAGR != 0 || st.Sharpe != 0 {
+		t.Errorf("stats of a single point = %+v, want zeros", st)
+	}
+}
@@ -0,0 +1,324 @@
+package qapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TimeRange is a period of time between Start and End.
+type TimeRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// Contains determines whether a time is within the range. The start is included
+// and the end is not. Zero-value bounds are unlimited.
+func (r TimeRange) Contains(t time.Time) bool {
+	return (r.Start.IsZero() || !t.Before(r.Start)) && (r.End.IsZero() || t.Before(r.End))
+}
+
+// CandleHistory is the cached candle history of a symbol at one interval.
+type CandleHistory struct {
+	// Candles sorted by start time.
+	Candles []Candlestick `json:"candles"`
+
+	// Ranges that have been retrieved from the API, sorted and not overlapping.
+	// Every candle that starts within them is cached, so a period within them
+	// without candles had no trading.
+	Covered []TimeRange `json:"covered"`
+}
+
+// CandleCache stores candle history, so that it doesn't have to be retrieved from
+// the API again. Load returns an empty history and a nil error if nothing has been
+// stored for the symbol and interval yet. Implementations must be safe for
+// concurrent use.
+type CandleCache interface {
+	Load(id int, interval CandleInterval) (CandleHistory, error)
+	Save(id int, interval CandleInterval, h CandleHistory) error
+}
+
+// FileCandleCache is a CandleCache that keeps the history of each symbol and
+// interval in a JSON file in a directory (e.g., "8049-OneDay.json"). Files are
+// replaced atomically on every save.
+type FileCandleCache struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+// NewFileCandleCache returns a CandleCache backed by files in the directory, which
+// is created if it doesn't exist.
+func NewFileCandleCache(dir string) (*FileCandleCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &FileCandleCache{Dir: dir}, nil
+}
+
+// path returns the path of the file holding a symbol's history at an interval
+func (f *FileCandleCache) path(id int, interval CandleInterval) string {
+	return filepath.Join(f.Dir, fmt.Sprintf("%d-%s.json", id, interval))
+}
+
+// Load reads the history from its file. A missing file is not an error.
+func (f *FileCandleCache) Load(id int, interval CandleInterval) (CandleHistory, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var h CandleHistory
+	data, err := ioutil.ReadFile(f.path(id, interval))
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+
+	err = json.Unmarshal(data, &h)
+	return h, err
+}
+
+// Save replaces the history in its file.
+func (f *FileCandleCache) Save(id int, interval CandleInterval, h CandleHistory) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.path(id, interval), data, 0644)
+}
+
+// MemoryCandleCache is a CandleCache that keeps history in memory, for the
+// lifetime of the program.
+type MemoryCandleCache struct {
+	histories map[string]CandleHistory
+	mu        sync.Mutex
+}
+
+// NewMemoryCandleCache returns an empty CandleCache.
+func NewMemoryCandleCache() *MemoryCandleCache {
+	return &MemoryCandleCache{histories: map[string]CandleHistory{}}
+}
+
+// Load returns the stored history.
+func (m *MemoryCandleCache) Load(id int, interval CandleInterval) (CandleHistory, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.histories[fmt.Sprintf("%d-%s", id, interval)], nil
+}
+
+// Save replaces the stored history.
+func (m *MemoryCandleCache) Save(id int, interval CandleInterval, h CandleHistory) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.histories[fmt.Sprintf("%d-%s", id, interval)] = h
+	return nil
+}
+
+// InvalidateCandles removes the candles that start at or after the start date and
+// before the end date from a cache, so that they are retrieved again. Use it after
+// a corporate action such as a split, which changes historical prices. A
+// zero-value start or end is unlimited, and an empty interval invalidates every
+// interval.
+func InvalidateCandles(cache CandleCache, id int, interval CandleInterval, start time.Time, end time.Time) error {
+	intervals := []CandleInterval{interval}
+	if interval == "" {
+		intervals = intervals[:0]
+		for iv := range candleIntervals {
+			intervals = append(intervals, iv)
+		}
+	}
+
+	if end.IsZero() {
+		end = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+	}
+	r := TimeRange{start, end}
+
+	for _, iv := range intervals {
+		h, err := cache.Load(id, iv)
+		if err != nil {
+			return err
+		}
+		if len(h.Candles) == 0 && len(h.Covered) == 0 {
+			continue
+		}
+
+		candles := []Candlestick{}
+		for _, c := range h.Candles {
+			if !r.Contains(c.Start) {
+				candles = append(candles, c)
+			}
+		}
+
+		covered := []TimeRange{}
+		for _, c := range h.Covered {
+			covered = append(covered, missingRanges([]TimeRange{r}, c)...)
+		}
+
+		if err := cache.Save(id, iv, CandleHistory{candles, covered}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cachedCandles retrieves candles through the client's cache. Only the parts of
+// the range that aren't cached are requested, and the new candles are added to
+// the cache. Candles that haven't ended yet are returned, but not cached.
+func (c *Client) cachedCandles(ctx context.Context, id int, start time.Time, end time.Time, interval CandleInterval) ([]Candlestick, error) {
+	h, err := c.candleCache.Load(id, interval)
+	if err != nil {
+		return []Candlestick{}, err
+	}
+
+	now := time.Now()
+	fetched := []Candlestick{}
+	covered := []TimeRange{}
+	f := &CandleFetcher{Client: c, uncached: true}
+	for _, r := range missingRanges(h.Covered, TimeRange{start, end}) {
+		candles, err := f.fetchPage(ctx, id, r.Start, r.End, interval, MaxCandlesPerRequest)
+		if err != nil {
+			return []Candlestick{}, err
+		}
+		fetched = append(fetched, candles...)
+
+		// The range is covered up to the first candle that is still forming, and
+		// no later than now
+		if r.End.After(now) {
+			r.End = now
+		}
+		for _, cs := range candles {
+			if cs.End.After(now) && cs.Start.Before(r.End) {
+				r.End = cs.Start
+			}
+		}
+		if r.End.After(r.Start) {
+			covered = append(covered, r)
+		}
+	}
+
+	if len(fetched) > 0 || len(covered) > 0 {
+		// The cache is read again, in case it was updated while the candles were
+		// being retrieved
+		c.candleMu.Lock()
+		err := c.updateCandleCache(id, interval, fetched, covered, now)
+		c.candleMu.Unlock()
+		if err != nil {
+			return []Candlestick{}, err
+		}
+	}
+
+	return candlesIn(mergeCandles(h.Candles, fetched), TimeRange{start, end}), nil
+}
+
+// updateCandleCache adds retrieved candles and the ranges they cover to the cache.
+// The caller must hold the candle lock.
+func (c *Client) updateCandleCache(id int, interval CandleInterval, fetched []Candlestick, covered []TimeRange, now time.Time) error {
+	h, err := c.candleCache.Load(id, interval)
+	if err != nil {
+		return err
+	}
+
+	complete := []Candlestick{}
+	for _, cs := range fetched {
+		if !cs.End.After(now) {
+			complete = append(complete, cs)
+		}
+	}
+	h.Candles = mergeCandles(h.Candles, complete)
+
+	for _, r := range covered {
+		h.Covered = addRange(h.Covered, r)
+	}
+	return c.candleCache.Save(id, interval, h)
+}
+
+// candlesIn returns the candles that start within a range
+func candlesIn(candles []Candlestick, r TimeRange) []Candlestick {
+	in := []Candlestick{}
+	for _, cs := range candles {
+		if r.Contains(cs.Start) {
+			in = append(in, cs)
+		}
+	}
+	return in
+}
+
+// mergeCandles combines two lists of candles in start time order. Candles in b
+// replace candles in a that start at the same time.
+func mergeCandles(a []Candlestick, b []Candlestick) []Candlestick {
+	byStart := map[int64]Candlestick{}
+	for _, cs := range a {
+		byStart[cs.Start.UnixNano()] = cs
+	}
+	for _, cs := range b {
+		byStart[cs.Start.UnixNano()] = cs
+	}
+
+	candles := make([]Candlestick, 0, len(byStart))
+	for _, cs := range byStart {
+		candles = append(candles, cs)
+	}
+	sort.Slice(candles, func(i, j int) bool {
+		return candles[i].Start.Before(candles[j].Start)
+	})
+	return candles
+}
+
+// missingRanges returns the parts of a range that aren't within any of the
+// sorted, non-overlapping covered ranges
+func missingRanges(covered []TimeRange, r TimeRange) []TimeRange {
+	missing := []TimeRange{}
+	cur := r.Start
+	for _, c := range covered {
+		if !c.End.After(cur) {
+			continue
+		}
+		if !c.Start.Before(r.End) {
+			break
+		}
+		if c.Start.After(cur) {
+			missing = append(missing, TimeRange{cur, c.Start})
+		}
+		cur = c.End
+	}
+	if cur.Before(r.End) {
+		missing = append(missing, TimeRange{cur, r.End})
+	}
+	return missing
+}
+
+// addRange adds a range to a sorted list of non-overlapping ranges, merging it
+// with the ranges that it overlaps or touches
+func addRange(ranges []TimeRange, r TimeRange) []TimeRange {
+	merged := []TimeRange{}
+	for _, c := range ranges {
+		switch {
+		case c.End.Before(r.Start):
+			merged = append(merged, c)
+		case c.Start.After(r.End):
+			merged = append(merged, c)
+		default:
+			if c.Start.Before(r.Start) {
+				r.Start = c.Start
+			}
+			if c.End.After(r.End) {
+				r.End = c.End
+			}
+		}
+	}
+
+	merged = append(merged, r)
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Start.Before(merged[j].Start)
+	})
+	return merged
+}
@@ -0,0 +1,325 @@
+package qapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// at returns a time on January 6, 2020
+func at(hour int, min int) time.Time {
+	return time.Date(2020, 1, 6, hour, min, 0, 0, time.UTC)
+}
+
+// hourly returns hour-long candles starting at each of the hours
+func hourly(hours ...int) []Candlestick {
+	candles := []Candlestick{}
+	for _, h := range hours {
+		candles = append(candles, Candlestick{Start: at(h, 0), End: at(h+1, 0)})
+	}
+	return candles
+}
+
+// starts returns the start times of candles
+func starts(candles []Candlestick) []string {
+	s := []string{}
+	for _, c := range candles {
+		s = append(s, c.Start.UTC().Format("15:04"))
+	}
+	return s
+}
+
+// candleServer is an API server with the candles of one symbol. Like the
+// Questrade API, it returns candles that start at the end of the requested range
+// too, and at most MaxCandlesPerRequest of them.
+type candleServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	candles  []Candlestick
+	requests []TimeRange
+}
+
+func newCandleServer(candles []Candlestick) *candleServer {
+	s := &candleServer{candles: candles}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, _ := time.Parse(time.RFC3339, r.URL.Query().Get("startTime"))
+		end, _ := time.Parse(time.RFC3339, r.URL.Query().Get("endTime"))
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, TimeRange{start, end})
+
+		out := struct {
+			Candles []Candlestick `json:"candles"`
+		}{[]Candlestick{}}
+		for _, c := range s.candles {
+			if len(out.Candles) < MaxCandlesPerRequest && !c.Start.Before(start) && !c.Start.After(end) {
+				out.Candles = append(out.Candles, c)
+			}
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	return s
+}
+
+// sent returns the ranges requested since the last call
+func (s *candleServer) sent() []TimeRange {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.requests
+	s.requests = nil
+	return r
+}
+
+// client returns a client of the server, with the given options
+func (s *candleServer) client(t *testing.T, opts ...ClientOption) *Client {
+	t.Helper()
+	opts = append(opts, WithCredentials(LoginCredentials{AccessToken: "access", TokenType: "Bearer", ApiServer: s.URL + "/"}))
+	c, err := NewClient("", true, opts...)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+func TestTimeRangeContains(t *testing.T) {
+	start := time.Date(2020, 1, 6, 14, 30, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		rng  TimeRange
+		t    time.Time
+		want bool
+	}{
+		{TimeRange{Start: start, End: end}, start, true},
+		{TimeRange{Start: start, End: end}, end.Add(-time.Nanosecond), true},
+		{TimeRange{Start: start, End: end}, end, false},
+		{TimeRange{Start: start, End: end}, start.Add(-time.Nanosecond), false},
+		{TimeRange{Start: start}, end.AddDate(10, 0, 0), true},
+		{TimeRange{End: end}, start.AddDate(-10, 0, 0), true},
+		{TimeRange{}, start, true},
+	}
+	for _, tt := range tests {
+		if got := tt.rng.Contains(tt.t); got != tt.want {
+			t.Errorf("%v.Contains(%v) = %v, want %v", tt.rng, tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestMissingRanges(t *testing.T) {
+	covered := []TimeRange{{at(10, 0), at(11, 0)}, {at(12, 0), at(13, 0)}}
+	tests := []struct {
+		r    TimeRange
+		want []TimeRange
+	}{
+		{TimeRange{at(10, 0), at(11, 0)}, []TimeRange{}},
+		{TimeRange{at(10, 15), at(10, 45)}, []TimeRange{}},
+		{TimeRange{at(9, 0), at(10, 0)}, []TimeRange{{at(9, 0), at(10, 0)}}},
+		{TimeRange{at(9, 0), at(10, 30)}, []TimeRange{{at(9, 0), at(10, 0)}}},
+		{TimeRange{at(10, 30), at(12, 30)}, []TimeRange{{at(11, 0), at(12, 0)}}},
+		{TimeRange{at(9, 0), at(14, 0)}, []TimeRange{{at(9, 0), at(10, 0)}, {at(11, 0), at(12, 0)}, {at(13, 0), at(14, 0)}}},
+		{TimeRange{at(13, 0), at(14, 0)}, []TimeRange{{at(13, 0), at(14, 0)}}},
+	}
+	for _, tt := range tests {
+		if got := missingRanges(covered, tt.r); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("missingRanges(%v) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+
+	if got := missingRanges(nil, TimeRange{at(9, 0), at(10, 0)}); len(got) != 1 {
+		t.Errorf("missingRanges without coverage = %v, want the whole range", got)
+	}
+}
+
+func TestAddRange(t *testing.T) {
+	ranges := []TimeRange{{at(10, 0), at(11, 0)}, {at(12, 0), at(13, 0)}}
+	tests := []struct {
+		r    TimeRange
+		want []TimeRange
+	}{
+		{TimeRange{at(8, 0), at(9, 0)}, []TimeRange{{at(8, 0), at(9, 0)}, {at(10, 0), at(11, 0)}, {at(12, 0), at(13, 0)}}},
+		{TimeRange{at(14, 0), at(15, 0)}, []TimeRange{{at(10, 0), at(11, 0)}, {at(12, 0), at(13, 0)}, {at(14, 0), at(15, 0)}}},
+		{TimeRange{at(10, 15), at(10, 45)}, []TimeRange{{at(10, 0), at(11, 0)}, {at(12, 0), at(13, 0)}}},
+		{TimeRange{at(9, 0), at(10, 30)}, []TimeRange{{at(9, 0), at(11, 0)}, {at(12, 0), at(13, 0)}}},
+		{TimeRange{at(11, 0), at(12, 0)}, []TimeRange{{at(10, 0), at(13, 0)}}},
+		{TimeRange{at(9, 0), at(14, 0)}, []TimeRange{{at(9, 0), at(14, 0)}}},
+	}
+	for _, tt := range tests {
+		in := append([]TimeRange{}, ranges...)
+		if got := addRange(in, tt.r); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("addRange(%v) = %v, want %v", tt.r, got, tt.want)
+		}
+	}
+}
+
+func TestCachedCandles(t *testing.T) {
+	srv := newCandleServer(hourly(8, 9, 10, 11, 12, 13))
+	defer srv.Close()
+	cache := NewMemoryCandleCache()
+	c := srv.client(t, WithCandleCache(cache))
+
+	// The candle that starts at the end of the range is left out
+	candles, err := c.GetCandles(8049, at(9, 0), at(12, 0), IntervalOneHour)
+	if got, want := fmt.Sprint(starts(candles)), "[09:00 10:00 11:00]"; err != nil || got != want {
+		t.Fatalf("GetCandles = %s %v, want %s", got, err, want)
+	}
+	if got := srv.sent(); len(got) != 1 {
+		t.Errorf("requests = %v, want 1", got)
+	}
+
+	// A cached range is read from the cache
+	candles, err = c.GetCandles(8049, at(10, 0), at(11, 0), IntervalOneHour)
+	if got, want := fmt.Sprint(starts(candles)), "[10:00]"; err != nil || got != want {
+		t.Errorf("cached GetCandles = %s %v, want %s", got, err, want)
+	}
+	if got := srv.sent(); len(got) != 0 {
+		t.Errorf("requests for a cached range = %v, want none", got)
+	}
+
+	// Only the parts that aren't cached are requested
+	candles, err = c.GetCandles(8049, at(8, 0), at(14, 0), IntervalOneHour)
+	if got, want := fmt.Sprint(starts(candles)), "[08:00 09:00 10:00 11:00 12:00 13:00]"; err != nil || got != want {
+		t.Errorf("GetCandles = %s %v, want %s", got, err, want)
+	}
+	want := []TimeRange{{at(8, 0), at(9, 0)}, {at(12, 0), at(14, 0)}}
+	if got := srv.sent(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+
+	h, err := cache.Load(8049, IntervalOneHour)
+	if err != nil || len(h.Candles) != 6 || fmt.Sprint(h.Covered) != fmt.Sprint([]TimeRange{{at(8, 0), at(14, 0)}}) {
+		t.Errorf("cached history = %v %v, want 6 candles covering 08:00 to 14:00", h, err)
+	}
+
+	// Without a cache, the same candles are returned
+	candles, err = srv.client(t).GetCandles(8049, at(9, 0), at(12, 0), IntervalOneHour)
+	if got, want := fmt.Sprint(starts(candles)), "[09:00 10:00 11:00]"; err != nil || got != want {
+		t.Errorf("uncached GetCandles = %s %v, want %s", got, err, want)
+	}
+}
+
+func TestCachedCandlesPages(t *testing.T) {
+	minutes := []Candlestick{}
+	for k := 0; k < MaxCandlesPerRequest+500; k++ {
+		start := at(0, 0).Add(time.Duration(k) * time.Minute)
+		minutes = append(minutes, Candlestick{Start: start, End: start.Add(time.Minute)})
+	}
+	srv := newCandleServer(minutes)
+	defer srv.Close()
+	c := srv.client(t, WithCandleCache(NewMemoryCandleCache()))
+
+	// The second page continues from the end of the first, and the cache doesn't
+	// send a request of its own
+	end := minutes[len(minutes)-1].End
+	candles, err := c.GetCandles(8049, at(0, 0), end, IntervalOneMinute)
+	if err != nil || len(candles) != len(minutes) {
+		t.Fatalf("GetCandles = %d candles %v, want %d", len(candles), err, len(minutes))
+	}
+	want := []TimeRange{{at(0, 0), end}, {minutes[MaxCandlesPerRequest-1].End, end}}
+	if got := srv.sent(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestCachedCandlesInProgress(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	forming := Candlestick{Start: now.Add(-30 * time.Minute), End: now.Add(30 * time.Minute)}
+	srv := newCandleServer([]Candlestick{{Start: forming.Start.Add(-time.Hour), End: forming.Start}, forming})
+	defer srv.Close()
+	cache := NewMemoryCandleCache()
+	c := srv.client(t, WithCandleCache(cache))
+
+	// The candle that hasn't ended is returned, but not cached
+	start, end := forming.Start.Add(-time.Hour), forming.End
+	candles, err := c.GetCandles(8049, start, end, IntervalOneHour)
+	if err != nil || len(candles) != 2 {
+		t.Fatalf("GetCandles = %v %v, want 2 candles", candles, err)
+	}
+	h, _ := cache.Load(8049, IntervalOneHour)
+	if len(h.Candles) != 1 || fmt.Sprint(h.Covered) != fmt.Sprint([]TimeRange{{start, forming.Start}}) {
+		t.Errorf("cached history = %v, want the complete candle covering up to the forming one", h)
+	}
+
+	// The forming candle is requested again
+	srv.sent()
+	if _, err := c.GetCandles(8049, start, end, IntervalOneHour); err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	if got, want := srv.sent(), []TimeRange{{forming.Start, end}}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestInvalidateCandles(t *testing.T) {
+	cache := NewMemoryCandleCache()
+	for _, iv := range []CandleInterval{IntervalOneHour, IntervalOneDay} {
+		cache.Save(8049, iv, CandleHistory{hourly(9, 10, 11), []TimeRange{{at(9, 0), at(12, 0)}}})
+	}
+
+	if err := InvalidateCandles(cache, 8049, IntervalOneHour, at(10, 0), at(11, 0)); err != nil {
+		t.Fatalf("InvalidateCandles: %v", err)
+	}
+	h, _ := cache.Load(8049, IntervalOneHour)
+	if got, want := fmt.Sprint(starts(h.Candles)), "[09:00 11:00]"; got != want {
+		t.Errorf("candles = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(h.Covered), fmt.Sprint([]TimeRange{{at(9, 0), at(10, 0)}, {at(11, 0), at(12, 0)}}); got != want {
+		t.Errorf("covered = %s, want %s", got, want)
+	}
+	if h, _ := cache.Load(8049, IntervalOneDay); len(h.Candles) != 3 {
+		t.Errorf("other interval has %d candles, want it unchanged", len(h.Candles))
+	}
+
+	// An empty interval and zero bounds invalidate everything
+	if err := InvalidateCandles(cache, 8049, "", time.Time{}, time.Time{}); err != nil {
+		t.Fatalf("InvalidateCandles: %v", err)
+	}
+	for _, iv := range []CandleInterval{IntervalOneHour, IntervalOneDay} {
+		if h, _ := cache.Load(8049, iv); len(h.Candles) != 0 || len(h.Covered) != 0 {
+			t.Errorf("%s history = %v, want it empty", iv, h)
+		}
+	}
+}
+
+func TestFileCandleCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "candles")
+	cache, err := NewFileCandleCache(dir)
+	if err != nil {
+		t.Fatalf("NewFileCandleCache: %v", err)
+	}
+
+	h, err := cache.Load(8049, IntervalOneHour)
+	if err != nil || len(h.Candles) != 0 || len(h.Covered) != 0 {
+		t.Errorf("Load of a missing file = %v %v, want an empty history", h, err)
+	}
+
+	saved := CandleHistory{hourly(9, 10), []TimeRange{{at(9, 0), at(11, 0)}}}
+	saved.Candles[0].Close = MustParseMoney("10.25")
+	if err := cache.Save(8049, IntervalOneHour, saved); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "8049-OneHour.json")); err != nil {
+		t.Errorf("cache file: %v", err)
+	}
+
+	// Another cache in the same directory reads the saved history
+	other, _ := NewFileCandleCache(dir)
+	h, err = other.Load(8049, IntervalOneHour)
+	if err != nil || fmt.Sprint(starts(h.Candles)) != "[09:00 10:00]" || h.Candles[0].Close.String() != "10.25" {
+		t.Errorf("Load = %v %v, want the saved candles", h, err)
+	}
+	if len(h.Covered) != 1 || !h.Covered[0].Start.Equal(at(9, 0)) || !h.Covered[0].End.Equal(at(11, 0)) {
+		t.Errorf("covered = %v, want 09:00 to 11:00", h.Covered)
+	}
+
+	os.WriteFile(filepath.Join(dir, "8049-OneDay.json"), []byte("{"), 0644)
+	if _, err := other.Load(8049, IntervalOneDay); err == nil {
+		t.Error("Load of a corrupt file: expected an error")
+	}
+}
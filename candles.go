@@ -0,0 +1,371 @@
+package qapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MaxCandlesPerRequest is the largest number of candles that Questrade returns for
+// a single candle request. Candles past the limit are silently left out.
+const MaxCandlesPerRequest = 2000
+
+// candleInterval describes a candle interval
+type candleInterval struct {
+	// Shortest length of a candle.
+	duration time.Duration
+
+	// Number of trading days in a candle, for intervals of a day or longer.
+	tradingDays int
+}
+
+// candleIntervals are the candle intervals supported by the API
+var candleIntervals = map[CandleInterval]candleInterval{
+	IntervalOneMinute:      {time.Minute, 0},
+	IntervalTwoMinutes:     {2 * time.Minute, 0},
+	IntervalThreeMinutes:   {3 * time.Minute, 0},
+	IntervalFourMinutes:    {4 * time.Minute, 0},
+	IntervalFiveMinutes:    {5 * time.Minute, 0},
+	IntervalTenMinutes:     {10 * time.Minute, 0},
+	IntervalFifteenMinutes: {15 * time.Minute, 0},
+	IntervalTwentyMinutes:  {20 * time.Minute, 0},
+	IntervalHalfHour:       {30 * time.Minute, 0},
+	IntervalOneHour:        {time.Hour, 0},
+	IntervalTwoHours:       {2 * time.Hour, 0},
+	IntervalFourHours:      {4 * time.Hour, 0},
+	IntervalOneDay:         {24 * time.Hour, 1},
+	IntervalOneWeek:        {7 * 24 * time.Hour, 5},
+	IntervalOneMonth:       {28 * 24 * time.Hour, 20},
+	IntervalOneYear:        {365 * 24 * time.Hour, 250},
+}
+
+// Duration returns the length of a candle at the interval. Months and years vary
+// in length, so their shortest length is returned (28 and 365 days). Returns zero
+// for unknown intervals.
+func (c CandleInterval) Duration() time.Duration {
+	return candleIntervals[c].duration
+}
+
+// MarketHours are the regular trading hours of a market, which explain why there
+// are no candles at some times. The market is open on weekdays that aren't
+// holidays, between the Open and Close times.
+type MarketHours struct {
+	// Time zone of the market. If nil, UTC is used.
+	Location *time.Location
+
+	// Opening and closing times, as the time since midnight.
+	Open  time.Duration
+	Close time.Duration
+
+	// Days that the market is closed, in addition to weekends. Only the date in
+	// the market's time zone is used.
+	Holidays []time.Time
+}
+
+// Hours returns the regular trading hours of the market, from its opening and
+// closing times on the current trading date. The time zone is the UTC offset of
+// the opening time, so it doesn't account for daylight saving time - set Location
+// if the hours are used across a time change.
+func (m Market) Hours() MarketHours {
+	loc := m.StartTime.Location()
+	midnight := day(m.StartTime, loc)
+	return MarketHours{
+		Location: loc,
+		Open:     m.StartTime.Sub(midnight),
+		Close:    m.EndTime.In(loc).Sub(midnight),
+	}
+}
+
+// location returns the time zone of the market
+func (h MarketHours) location() *time.Location {
+	if h.Location == nil {
+		return time.UTC
+	}
+	return h.Location
+}
+
+// IsTradingDay determines whether the market opens on the date of t, in the
+// market's time zone.
+func (h MarketHours) IsTradingDay(t time.Time) bool {
+	t = t.In(h.location())
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+
+	y, m, d := t.Date()
+	for _, hol := range h.Holidays {
+		hy, hm, hd := hol.In(h.location()).Date()
+		if y == hy && m == hm && d == hd {
+			return false
+		}
+	}
+	return true
+}
+
+// IsOpen determines whether the market is open at a time.
+func (h MarketHours) IsOpen(t time.Time) bool {
+	if !h.IsTradingDay(t) {
+		return false
+	}
+	open, close := h.session(t)
+	return !t.Before(open) && t.Before(close)
+}
+
+// session returns the opening and closing times on the date of t
+func (h MarketHours) session(t time.Time) (time.Time, time.Time) {
+	midnight := day(t, h.location())
+	return midnight.Add(h.Open), midnight.Add(h.Close)
+}
+
+// openTime returns how long the market is open between a and b
+func (h MarketHours) openTime(a time.Time, b time.Time) time.Duration {
+	var total time.Duration
+	for d := day(a, h.location()); d.Before(b); d = d.AddDate(0, 0, 1) {
+		if !h.IsTradingDay(d) {
+			continue
+		}
+
+		open, close := h.session(d)
+		if open.Before(a) {
+			open = a
+		}
+		if close.After(b) {
+			close = b
+		}
+		if close.After(open) {
+			total += close.Sub(open)
+		}
+	}
+	return total
+}
+
+// tradingDays returns the number of trading days that the market opens between a and b
+func (h MarketHours) tradingDays(a time.Time, b time.Time) int {
+	n := 0
+	for d := day(a, h.location()); d.Before(b); d = d.AddDate(0, 0, 1) {
+		open, _ := h.session(d)
+		if h.IsTradingDay(d) && !open.Before(a) && open.Before(b) {
+			n++
+		}
+	}
+	return n
+}
+
+// day returns midnight on the date of t in the given time zone
+func day(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// CandleGap is a period in which candles were expected, but none were returned.
+type CandleGap struct {
+	Start time.Time
+	End   time.Time
+}
+
+// CandleFetcher retrieves candles over ranges of any length. The range is split
+// into requests that each return fewer candles than the API's limit, which are
+// sent concurrently, and the results are combined. The client's rate limiting
+// applies to the requests.
+type CandleFetcher struct {
+	// Client used to send requests.
+	Client *Client
+
+	// Largest number of candles that a request is expected to return. A request
+	// that returns this many candles is continued from its last candle. Defaults
+	// to MaxCandlesPerRequest.
+	PageSize int
+
+	// Number of requests sent at the same time. Defaults to 4.
+	Concurrency int
+
+	// Trading hours of the symbol's market, which are used to tell which periods
+	// without candles are gaps. If nil, the market is treated as always open.
+	Hours *MarketHours
+
+	// Whether requests bypass the client's candle cache, for the cache's own use
+	uncached bool
+}
+
+// NewCandleFetcher creates a candle fetcher with the default settings.
+func NewCandleFetcher(c *Client) *CandleFetcher {
+	return &CandleFetcher{Client: c}
+}
+
+// Fetch retrieves the candles of a symbol between the start and end dates, in the
+// given data granularity. The candles are sorted by start time, without
+// duplicates. Also returns the periods in which the market was open for at least
+// one interval (or trading day, for intervals of a day or longer) without any
+// candles, such as trading halts or missing data. Both dates are required, and the
+// start must not be after the end.
+func (f *CandleFetcher) Fetch(ctx context.Context, id int, start time.Time, end time.Time, interval CandleInterval) ([]Candlestick, []CandleGap, error) {
+	iv, ok := candleIntervals[interval]
+	if !ok {
+		return []Candlestick{}, []CandleGap{}, fmt.Errorf("Error: Unknown candle interval %s", interval)
+	}
+	if start.IsZero() || end.IsZero() {
+		return []Candlestick{}, []CandleGap{}, errors.New("Error: Candles require a start and end time")
+	}
+	if start.After(end) {
+		return []Candlestick{}, []CandleGap{}, errors.New("Error: Start time is after end time")
+	}
+
+	pageSize := f.PageSize
+	if pageSize <= 0 {
+		pageSize = MaxCandlesPerRequest
+	}
+	workers := f.Concurrency
+	if workers <= 0 {
+		workers = 4
+	}
+
+	// Each page covers a range that can't hold more candles than the page size,
+	// even if the market never closed
+	span := iv.duration * time.Duration(pageSize)
+	type page struct {
+		start, end time.Time
+	}
+	pages := []page{}
+	for s := start; s.Before(end); s = s.Add(span) {
+		e := s.Add(span)
+		if e.After(end) {
+			e = end
+		}
+		pages = append(pages, page{s, e})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([][]Candlestick, len(pages))
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var firstErr error
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range queue {
+				candles, err := f.fetchPage(ctx, id, pages[k].start, pages[k].end, interval, pageSize)
+				if err != nil {
+					once.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					continue
+				}
+				results[k] = candles
+			}
+		}()
+	}
+
+	for k := range pages {
+		select {
+		case queue <- k:
+		case <-ctx.Done():
+		}
+	}
+	close(queue)
+	wg.Wait()
+
+	if firstErr != nil {
+		return []Candlestick{}, []CandleGap{}, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return []Candlestick{}, []CandleGap{}, err
+	}
+
+	candles := stitchCandles(results)
+	return candles, f.gaps(candles, start, end, iv), nil
+}
+
+// fetchPage retrieves the candles in a range, continuing from the last candle
+// received while the server returns full pages
+func (f *CandleFetcher) fetchPage(ctx context.Context, id int, start time.Time, end time.Time, interval CandleInterval, pageSize int) ([]Candlestick, error) {
+	get := f.Client.GetCandlesContext
+	if f.uncached {
+		get = f.Client.getCandles
+	}
+
+	candles := []Candlestick{}
+	for {
+		page, err := get(ctx, id, start, end, interval)
+		if err != nil {
+			return nil, err
+		}
+		candles = append(candles, page...)
+
+		if len(page) < pageSize {
+			return candles, nil
+		}
+		next := page[len(page)-1].End
+		if !next.After(start) || !next.Before(end) {
+			return candles, nil
+		}
+		start = next
+	}
+}
+
+// stitchCandles combines pages of candles in start time order, dropping candles
+// that were returned by more than one page
+func stitchCandles(pages [][]Candlestick) []Candlestick {
+	all := []Candlestick{}
+	for _, p := range pages {
+		all = append(all, p...)
+	}
+
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Start.Before(all[j].Start)
+	})
+
+	candles := []Candlestick{}
+	for _, c := range all {
+		if len(candles) > 0 && candles[len(candles)-1].Start.Equal(c.Start) {
+			continue
+		}
+		candles = append(candles, c)
+	}
+	return candles
+}
+
+// gaps finds the periods between candles in which the market was open long enough
+// for at least one candle
+func (f *CandleFetcher) gaps(candles []Candlestick, start time.Time, end time.Time, iv candleInterval) []CandleGap {
+	hours := MarketHours{}
+	if f.Hours != nil {
+		hours = *f.Hours
+	}
+
+	missing := func(a, b time.Time) bool {
+		if !b.After(a) {
+			return false
+		}
+		if f.Hours == nil {
+			return b.Sub(a) >= iv.duration
+		}
+		if iv.tradingDays > 0 {
+			return hours.tradingDays(a, b) >= iv.tradingDays
+		}
+		return hours.openTime(a, b) >= iv.duration
+	}
+
+	gaps := []CandleGap{}
+	prev := start
+	for _, c := range candles {
+		if missing(prev, c.Start) {
+			gaps = append(gaps, CandleGap{prev, c.Start})
+		}
+		if c.End.After(prev) {
+			prev = c.End
+		}
+	}
+	if missing(prev, end) {
+		gaps = append(gaps, CandleGap{prev, end})
+	}
+	return gaps
+}
@@ -0,0 +1,36 @@
+package qapi_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/qapitest"
+)
+
+func TestCandleFetcherRejectsInvalidRange(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+
+	c, err := srv.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	f := qapi.NewCandleFetcher(c)
+
+	now := time.Now()
+	ranges := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"zero start", time.Time{}, now},
+		{"zero end", now, time.Time{}},
+		{"inverted", now, now.Add(-time.Hour)},
+	}
+	for _, r := range ranges {
+		if _, _, err := f.Fetch(context.Background(), 8049, r.start, r.end, qapi.IntervalOneMinute); err == nil {
+			t.Errorf("Fetch with %s range: expected an error", r.name)
+		}
+	}
+}
@@ -2,76 +2,162 @@
 //
 // Please note this is not an official API wrapper, and is not endorsed by Questrade. Please see
 // http://www.questrade.com/api/home for official documentation.
+//
+// Each Client method that sends requests has a variant ending in Context, such as
+// GetAccountsContext, that takes a context to cancel the requests or set their
+// deadline. The methods without a context use context.Background.
 package qapi
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
+// activityWindow is the longest range of time requested in a single call to the
+// activities endpoint, which rejects ranges of more than 31 days.
+const activityWindow = 30 * 24 * time.Hour
+
+// refreshMargin is how long before the access token expires that the client
+// will proactively exchange the refresh token for a new session.
+const refreshMargin = time.Minute
+
 // A client is the structure that will be used to consume the API
 // endpoints. It holds the login credentials, http client/transport,
-// rate limit information, and the login session timer.
+// and rate limit information. The client refreshes its own access token
+// before the login session expires, so it is safe to use for long periods
+// of time and from multiple goroutines.
 type Client struct {
-	Credentials        LoginCredentials
-	SessionTimer       *time.Timer
-	RateLimitRemaining int
-	RateLimitReset     time.Time
-	httpClient         *http.Client
-	transport          *http.Transport
+	Credentials LoginCredentials
+
+	// RetryPolicy controls how requests that fail with a transient error are
+	// retried. It should not be modified while requests are in progress.
+	RetryPolicy RetryPolicy
+
+	practice      bool
+	loginURL      string
+	apiServer     string
+	skipLogin     bool
+	validate      bool
+	strictEnums   bool
+	timeout       time.Duration
+	store         TokenStore
+	ownToken      bool // the first login uses the caller's refresh token, not the store's; guarded by loginLock
+	candleCache   CandleCache
+	candleMu      sync.Mutex // serializes updates to the candle cache
+	sessionExpiry time.Time
+	limiter       rateLimiter
+	mu            sync.Mutex    // guards Credentials and sessionExpiry
+	loginLock     chan struct{} // serializes logins so a refresh token is only exchanged once
+	httpClient    *http.Client
+	transport     *http.Transport
 }
 
 // Send an HTTP GET request, and return the processed response
-func (c *Client) get(endpoint string, out interface{}, query url.Values) error {
-	req, err := http.NewRequest("GET", c.Credentials.ApiServer+endpoint+query.Encode(), nil)
-	if err != nil {
-		return err
-	}
-	req.Header.Add("Authorization", c.Credentials.authHeader())
-
-	res, err := c.httpClient.Do(req)
-
-	err = c.processResponse(res, out)
-	if err != nil {
-		return err
-	}
-	return nil
+func (c *Client) get(ctx context.Context, endpoint string, out interface{}, query url.Values) error {
+	return c.do(ctx, "GET", endpoint, query, nil, out)
 }
 
 // Format the message body, send an HTTP POST request, and return the processed response
-func (c *Client) post(endpoint string, out interface{}, body interface{}) error {
+func (c *Client) post(ctx context.Context, endpoint string, out interface{}, body interface{}) error {
+	if c.strictEnums {
+		if err := checkEnums(body); err != nil {
+			return err
+		}
+	}
+
 	// Attempt to marshall the body as JSON
 	json, err := json.Marshal(body)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequest("POST", c.Credentials.ApiServer+endpoint, bytes.NewBuffer(json))
-	if err != nil {
+	return c.do(ctx, "POST", endpoint, url.Values{}, json, out)
+}
+
+// do sends an authenticated request to the API server and processes the response.
+// GET requests that fail with a transient error are retried according to the
+// client's retry policy - other requests are only sent once.
+func (c *Client) do(ctx context.Context, method string, endpoint string, query url.Values, body []byte, out interface{}) error {
+	policy := c.RetryPolicy
+	if method != "GET" {
+		policy.MaxAttempts = 1
+	}
+
+	return c.retry(ctx, policy, func(error) error {
+		return c.send(ctx, method, endpoint, query, body, out)
+	})
+}
+
+// send makes a single attempt at an API request. If the login session is about
+// to expire, the access token is refreshed before the request is sent. If the
+// server rejects the access token, the session is refreshed and the request is
+// sent once more.
+func (c *Client) send(ctx context.Context, method string, endpoint string, query url.Values, body []byte, out interface{}) error {
+	if err := c.ensureSession(ctx); err != nil {
 		return err
 	}
-	req.Header.Add("Authorization", c.Credentials.authHeader())
 
-	res, err := c.httpClient.Do(req)
+	cat := rateLimitCategory(endpoint)
 
-	err = c.processResponse(res, out)
-	if err != nil {
+	for retried := false; ; retried = true {
+		if err := c.limiter.wait(ctx, cat); err != nil {
+			return err
+		}
+
+		creds := c.credentials()
+
+		u := creds.ApiServer + endpoint
+		if len(query) > 0 {
+			u += "?" + query.Encode()
+		}
+
+		// The body is re-read on every attempt, so a fresh reader is required
+		var r io.Reader
+		if body != nil {
+			r = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequest(method, u, r)
+		if err != nil {
+			return err
+		}
+		req = req.WithContext(ctx)
+		req.Header.Add("Authorization", creds.authHeader())
+		if body != nil {
+			req.Header.Add("Content-Type", "application/json")
+		}
+
+		res, err := c.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		c.limiter.update(cat, res)
+
+		err = c.processResponse(res, out)
+		if qe, ok := err.(QuestradeError); ok && qe.StatusCode == http.StatusUnauthorized && !retried {
+			if err := c.refresh(ctx, creds.AccessToken); err != nil {
+				return err
+			}
+			continue
+		}
 		return err
 	}
-	return nil
 }
 
 // processResponse takes the body of an HTTP response, and either returns
-// the error code, or unmarshalls the JSON response, extracts
-// rate limit info, and places it into the object
-// output parameter. This function closes the response body after reading it.
+// the error code, or unmarshalls the JSON response and places it
+// into the object output parameter. This function closes the response body after reading it.
 func (c *Client) processResponse(res *http.Response, out interface{}) error {
 	body, err := ioutil.ReadAll(res.Body)
 	res.Body.Close()
@@ -83,42 +169,181 @@ func (c *Client) processResponse(res *http.Response, out interface{}) error {
 		return newQuestradeError(res, body)
 	}
 
-	err = json.Unmarshal(body, out)
+	return c.decode(body, out)
+}
+
+// decode unmarshalls a JSON message from the server. If the client is strict about
+// enumerations, undocumented values are reported as errors.
+func (c *Client) decode(data []byte, out interface{}) error {
+	if err := json.Unmarshal(data, out); err != nil {
+		return err
+	}
+
+	if c.strictEnums {
+		return checkEnums(out)
+	}
+	return nil
+}
+
+// postForm sends an unauthenticated, form encoded HTTP POST request to the login server
+func (c *Client) postForm(ctx context.Context, u string, vars url.Values) (*http.Response, error) {
+	req, err := http.NewRequest("POST", u, strings.NewReader(vars.Encode()))
 	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return c.httpClient.Do(req)
+}
+
+// credentials returns a copy of the current login credentials
+func (c *Client) credentials() LoginCredentials {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.Credentials
+}
+
+// SessionExpiry returns the time at which the current access token expires.
+// The client will log in again automatically shortly before this time.
+func (c *Client) SessionExpiry() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.sessionExpiry
+}
+
+// ensureSession refreshes the access token if the login session has expired
+// or is about to. If the client was given credentials without logging in, the
+// expiry time is unknown and the session is only refreshed once the server
+// rejects the access token.
+func (c *Client) ensureSession(ctx context.Context) error {
+	c.mu.Lock()
+	token := c.Credentials.AccessToken
+	expiring := token == "" || (!c.sessionExpiry.IsZero() && time.Now().Add(refreshMargin).After(c.sessionExpiry))
+	c.mu.Unlock()
+
+	if !expiring {
+		return nil
+	}
+	return c.refresh(ctx, token)
+}
+
+// refresh exchanges the refresh token for a new access token, unless another
+// goroutine has already replaced the stale access token in the meantime.
+func (c *Client) refresh(ctx context.Context, stale string) error {
+	if err := c.lockLogin(ctx); err != nil {
 		return err
 	}
+	defer c.unlockLogin()
 
-	reset, _ := strconv.Atoi(res.Header.Get("X-RateLimit-Reset"))
-	c.RateLimitReset = time.Unix(int64(reset), 0)
-	c.RateLimitRemaining, _ = strconv.Atoi(res.Header.Get("X-RateLimit-Remaining"))
+	if c.credentials().AccessToken != stale {
+		return nil
+	}
+	return c.login(ctx)
+}
 
-	return nil
+// lockLogin acquires the login lock, giving up if the context is done first.
+func (c *Client) lockLogin(ctx context.Context) error {
+	select {
+	case c.loginLock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// unlockLogin releases the login lock.
+func (c *Client) unlockLogin() {
+	<-c.loginLock
 }
 
 // Login takes the refresh token from the client login credentials
-// and exchanges it for an access token. Returns a timer that
-// expires when the login session is over. If the practice flag is
-// true, then the client will log into the practice server.
+// and exchanges it for an access token. If the practice flag is
+// true, then the client will log into the practice server. Subsequent
+// automatic refreshes of the session use the same server.
 // TODO - Return a proper error when login fails with HTTP 400 - Bad Request
 func (c *Client) Login(practice bool) error {
-	login := loginServerURL
-	if practice {
-		login = practiceLoginServerURL
+	return c.LoginContext(context.Background(), practice)
+}
+
+// LoginContext is like Login, but uses the provided context for the request.
+func (c *Client) LoginContext(ctx context.Context, practice bool) error {
+	if err := c.lockLogin(ctx); err != nil {
+		return err
 	}
+	defer c.unlockLogin()
+
+	c.mu.Lock()
+	c.practice = practice
+	c.mu.Unlock()
 
-	vars := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {c.Credentials.RefreshToken}}
-	res, err := c.httpClient.PostForm(login+"token", vars)
+	return c.login(ctx)
+}
+
+// loginServer returns the URL of the login server the client uses. The caller
+// must hold mu.
+func (c *Client) loginServer() string {
+	if c.loginURL != "" {
+		return c.loginURL
+	}
+	if c.practice {
+		return practiceLoginServerURL
+	}
+	return loginServerURL
+}
+
+// login performs the token exchange. If the client has a token store, the
+// refresh token is read from it beforehand, unless this is the first login with a
+// token given to NewClient, and the replacement credentials are written to it
+// afterwards. The caller must hold the login lock.
+func (c *Client) login(ctx context.Context) error {
+	c.mu.Lock()
+	login := c.loginServer()
+	refreshToken := c.Credentials.RefreshToken
+	c.mu.Unlock()
+
+	// Another process sharing the store may have rotated the token since it was last read
+	ownToken := c.ownToken
+	c.ownToken = false
+	if c.store != nil && !ownToken {
+		stored, err := c.store.Load()
+		if err != nil {
+			return err
+		}
+		if stored.RefreshToken != "" {
+			refreshToken = stored.RefreshToken
+		}
+	}
+
+	vars := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
+	res, err := c.postForm(ctx, login+"token", vars)
 
 	if err != nil {
 		return err
 	}
 
-	err = c.processResponse(res, &c.Credentials)
+	var creds LoginCredentials
+	err = c.processResponse(res, &creds)
 	if err != nil {
 		return err
 	}
 
-	c.SessionTimer = time.NewTimer(time.Duration(c.Credentials.ExpiresIn) * time.Second)
+	if c.apiServer != "" {
+		creds.ApiServer = c.apiServer
+	}
+
+	c.mu.Lock()
+	c.Credentials = creds
+	c.sessionExpiry = time.Now().Add(time.Duration(creds.ExpiresIn) * time.Second)
+	c.mu.Unlock()
+
+	// The old refresh token is no longer valid, so failing to persist the new
+	// one is reported even though the session itself is usable.
+	if c.store != nil {
+		if err := c.store.Save(creds); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
@@ -127,19 +352,26 @@ func (c *Client) Login(practice bool) error {
 // NOTE - You will have to create another manual authorization
 // on the Questrade website to use an application again.
 func (c *Client) RevokeAuth() error {
-	vars := url.Values{"token": {c.Credentials.AccessToken}}
+	return c.RevokeAuthContext(context.Background())
+}
 
-	res, err := c.httpClient.PostForm(loginServerURL+"revoke", vars)
+// RevokeAuthContext is like RevokeAuth, but uses the provided context for the request.
+func (c *Client) RevokeAuthContext(ctx context.Context) error {
+	c.mu.Lock()
+	vars := url.Values{"token": {c.Credentials.AccessToken}}
+	login := c.loginServer()
 
 	// Even though the user may still be logged in if there was an error
 	// I'm going to set the login info to nil anyways
 	c.Credentials = LoginCredentials{}
+	c.sessionExpiry = time.Time{}
+	c.mu.Unlock()
 
-	defer res.Body.Close()
-
+	res, err := c.postForm(ctx, login+"revoke", vars)
 	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -155,11 +387,16 @@ func (c *Client) RevokeAuth() error {
 
 // GetServerTime retrieves the current time on Questrade's server
 func (c *Client) GetServerTime() (time.Time, error) {
+	return c.GetServerTimeContext(context.Background())
+}
+
+// GetServerTimeContext is like GetServerTime, but uses the provided context for the request.
+func (c *Client) GetServerTimeContext(ctx context.Context) (time.Time, error) {
 	t := struct {
-		Time time.Time `json:"time",string`
+		Time time.Time `json:"time"`
 	}{}
 
-	err := c.get("v1/time", &t, url.Values{})
+	err := c.get(ctx, "v1/time", &t, url.Values{})
 	if err != nil {
 		return time.Time{}, err
 	}
@@ -170,12 +407,17 @@ func (c *Client) GetServerTime() (time.Time, error) {
 // GetAccounts returns the logged-in User ID, and a list of accounts
 // belonging to that user.
 func (c *Client) GetAccounts() (int, []Account, error) {
+	return c.GetAccountsContext(context.Background())
+}
+
+// GetAccountsContext is like GetAccounts, but uses the provided context for the request.
+func (c *Client) GetAccountsContext(ctx context.Context) (int, []Account, error) {
 	list := struct {
-		UserID   int       `json":"userId"`
-		Accounts []Account `json":"accounts"`
+		UserID   int       `json:"userId"`
+		Accounts []Account `json:"accounts"`
 	}{}
 
-	err := c.get("v1/accounts", &list, url.Values{})
+	err := c.get(ctx, "v1/accounts", &list, url.Values{})
 	if err != nil {
 		return 0, []Account{}, err
 	}
@@ -185,9 +427,14 @@ func (c *Client) GetAccounts() (int, []Account, error) {
 
 // GetBalances returns the balances for the account with the specified account number
 func (c *Client) GetBalances(number string) (AccountBalances, error) {
+	return c.GetBalancesContext(context.Background(), number)
+}
+
+// GetBalancesContext is like GetBalances, but uses the provided context for the request.
+func (c *Client) GetBalancesContext(ctx context.Context, number string) (AccountBalances, error) {
 	bal := AccountBalances{}
 
-	err := c.get("v1/accounts/"+number+"/balances", &bal, url.Values{})
+	err := c.get(ctx, "v1/accounts/"+number+"/balances", &bal, url.Values{})
 	if err != nil {
 		return AccountBalances{}, err
 	}
@@ -195,10 +442,34 @@ func (c *Client) GetBalances(number string) (AccountBalances, error) {
 	return bal, nil
 }
 
+// GetPositions returns the positions for the account with the specified account number
+func (c *Client) GetPositions(number string) ([]Position, error) {
+	return c.GetPositionsContext(context.Background(), number)
+}
+
+// GetPositionsContext is like GetPositions, but uses the provided context for the request.
+func (c *Client) GetPositionsContext(ctx context.Context, number string) ([]Position, error) {
+	p := struct {
+		Positions []Position `json:"positions"`
+	}{}
+
+	err := c.get(ctx, "v1/accounts/"+number+"/positions", &p, url.Values{})
+	if err != nil {
+		return []Position{}, err
+	}
+
+	return p.Positions, nil
+}
+
 // GetExecutions returns the number of executions for a given account between the start and end times
 // If the times are zero-value, then the API will default the start and end times to the beginning
 // and end of the current day.
 func (c *Client) GetExecutions(number string, start time.Time, end time.Time) ([]Execution, error) {
+	return c.GetExecutionsContext(context.Background(), number, start, end)
+}
+
+// GetExecutionsContext is like GetExecutions, but uses the provided context for the request.
+func (c *Client) GetExecutionsContext(ctx context.Context, number string, start time.Time, end time.Time) ([]Execution, error) {
 	// Format the times if they are not zero-values
 	params := url.Values{}
 	if !start.Equal(time.Time{}) {
@@ -213,7 +484,7 @@ func (c *Client) GetExecutions(number string, start time.Time, end time.Time) ([
 		Executions []Execution `json:"executions"`
 	}{}
 
-	err := c.get("v1/accounts/"+number+"/executions?", &exec, params)
+	err := c.get(ctx, "v1/accounts/"+number+"/executions", &exec, params)
 
 	if err != nil {
 		return []Execution{}, err
@@ -222,13 +493,75 @@ func (c *Client) GetExecutions(number string, start time.Time, end time.Time) ([
 	return exec.Executions, nil
 }
 
+// GetActivities returns the account activities between the start and end times. The API
+// only returns up to 31 days of activities per request, so longer ranges are split into
+// multiple requests, and the results are merged in chronological order. Both times are
+// required, and the start must not be after the end.
+func (c *Client) GetActivities(number string, start time.Time, end time.Time) ([]Activity, error) {
+	return c.GetActivitiesContext(context.Background(), number, start, end)
+}
+
+// GetActivitiesContext is like GetActivities, but uses the provided context for the requests.
+func (c *Client) GetActivitiesContext(ctx context.Context, number string, start time.Time, end time.Time) ([]Activity, error) {
+	if start.IsZero() || end.IsZero() {
+		return []Activity{}, errors.New("Error: Activities require a start and end time")
+	}
+	if start.After(end) {
+		return []Activity{}, errors.New("Error: Start time is after end time")
+	}
+
+	activities := []Activity{}
+
+	// Activities at the boundary of two windows may be returned by both requests
+	prev := map[string]bool{}
+
+	for from := start; from.Before(end); {
+		to := from.Add(activityWindow)
+		if to.After(end) {
+			to = end
+		}
+
+		params := url.Values{}
+		params.Add("startTime", from.Format(time.RFC3339))
+		params.Add("endTime", to.Format(time.RFC3339))
+
+		a := struct {
+			Activities []Activity `json:"activities"`
+		}{}
+
+		err := c.get(ctx, "v1/accounts/"+number+"/activities", &a, params)
+		if err != nil {
+			return []Activity{}, err
+		}
+
+		seen := map[string]bool{}
+		for _, act := range a.Activities {
+			key := fmt.Sprintf("%+v", act)
+			seen[key] = true
+			if !prev[key] {
+				activities = append(activities, act)
+			}
+		}
+
+		prev = seen
+		from = to
+	}
+
+	return activities, nil
+}
+
 // GetOrders returns orders for a specified account. Will return results based on the start and
 // end times, and the order state. Use GetOrdersByID() to retrieve individual order details.
 // If the times are zero-value, then the API will default the start and end times to the beginning
 // and end of the current day.
 // TODO - Verify order state enumeration in accordance with API docs
 // See: http://www.questrade.com/api/documentation/rest-operations/account-calls/accounts-id-orders
-func (c *Client) GetOrders(number string, start time.Time, end time.Time, state string) ([]Order, error) {
+func (c *Client) GetOrders(number string, start time.Time, end time.Time, state OrderStateFilter) ([]Order, error) {
+	return c.GetOrdersContext(context.Background(), number, start, end, state)
+}
+
+// GetOrdersContext is like GetOrders, but uses the provided context for the request.
+func (c *Client) GetOrdersContext(ctx context.Context, number string, start time.Time, end time.Time, state OrderStateFilter) ([]Order, error) {
 	// Format the times if they are not zero-values
 	params := url.Values{}
 	if !start.Equal(time.Time{}) {
@@ -239,13 +572,13 @@ func (c *Client) GetOrders(number string, start time.Time, end time.Time, state
 		params.Add("endTime", end.Format(time.RFC3339))
 	}
 
-	params.Add("stateFilter", state)
+	params.Add("stateFilter", string(state))
 
 	o := struct {
 		Orders []Order `json:"orders"`
 	}{}
 
-	err := c.get("v1/accounts/"+number+"/orders?", &o, params)
+	err := c.get(ctx, "v1/accounts/"+number+"/orders", &o, params)
 	if err != nil {
 		return []Order{}, err
 	}
@@ -255,6 +588,11 @@ func (c *Client) GetOrders(number string, start time.Time, end time.Time, state
 
 // GetOrdersByID returns the orders specified by the list of OrderID's
 func (c *Client) GetOrdersByID(number string, orderIds ...int) ([]Order, error) {
+	return c.GetOrdersByIDContext(context.Background(), number, orderIds...)
+}
+
+// GetOrdersByIDContext is like GetOrdersByID, but uses the provided context for the request.
+func (c *Client) GetOrdersByIDContext(ctx context.Context, number string, orderIds ...int) ([]Order, error) {
 	idStr := ""
 	for k, v := range orderIds {
 		idStr += strconv.Itoa(v)
@@ -270,7 +608,7 @@ func (c *Client) GetOrdersByID(number string, orderIds ...int) ([]Order, error)
 		Orders []Order `json:"orders"`
 	}{}
 
-	err := c.get("v1/accounts/"+number+"/orders?", &o, params)
+	err := c.get(ctx, "v1/accounts/"+number+"/orders", &o, params)
 	if err != nil {
 		return []Order{}, err
 	}
@@ -280,6 +618,11 @@ func (c *Client) GetOrdersByID(number string, orderIds ...int) ([]Order, error)
 
 // GetSymbols returns detailed symbol information for the given symbol ID's
 func (c *Client) GetSymbols(ids ...int) ([]Symbol, error) {
+	return c.GetSymbolsContext(context.Background(), ids...)
+}
+
+// GetSymbolsContext is like GetSymbols, but uses the provided context for the request.
+func (c *Client) GetSymbolsContext(ctx context.Context, ids ...int) ([]Symbol, error) {
 	idStr := ""
 	for k, v := range ids {
 		idStr += strconv.Itoa(v)
@@ -295,7 +638,7 @@ func (c *Client) GetSymbols(ids ...int) ([]Symbol, error) {
 		Symbols []Symbol `json:"symbols"`
 	}{}
 
-	err := c.get("v1/symbols?", &s, params)
+	err := c.get(ctx, "v1/symbols", &s, params)
 	if err != nil {
 		return []Symbol{}, err
 	}
@@ -306,6 +649,11 @@ func (c *Client) GetSymbols(ids ...int) ([]Symbol, error) {
 // SearchSymbols returns symbol search matches for a symbol prefix, at a given offset from the
 // beginning of the search results.
 func (c *Client) SearchSymbols(prefix string, offset int) ([]SymbolSearchResult, error) {
+	return c.SearchSymbolsContext(context.Background(), prefix, offset)
+}
+
+// SearchSymbolsContext is like SearchSymbols, but uses the provided context for the request.
+func (c *Client) SearchSymbolsContext(ctx context.Context, prefix string, offset int) ([]SymbolSearchResult, error) {
 	params := url.Values{}
 	params.Add("prefix", prefix)
 	params.Add("offset", strconv.Itoa(offset))
@@ -314,7 +662,7 @@ func (c *Client) SearchSymbols(prefix string, offset int) ([]SymbolSearchResult,
 		Symbols []SymbolSearchResult `json:"symbols"`
 	}{}
 
-	err := c.get("v1/symbols/search?", &s, params)
+	err := c.get(ctx, "v1/symbols/search", &s, params)
 	if err != nil {
 		return []SymbolSearchResult{}, err
 	}
@@ -325,11 +673,16 @@ func (c *Client) SearchSymbols(prefix string, offset int) ([]SymbolSearchResult,
 // GetOptionChain Retrieves an option chain for a particular underlying symbol.
 // TODO - More comprehensive tests - perhaps I should learn what an option chain is?
 func (c *Client) GetOptionChain(id int) ([]OptionChain, error) {
+	return c.GetOptionChainContext(context.Background(), id)
+}
+
+// GetOptionChainContext is like GetOptionChain, but uses the provided context for the request.
+func (c *Client) GetOptionChainContext(ctx context.Context, id int) ([]OptionChain, error) {
 	o := struct {
 		Options []OptionChain `json:"options"`
 	}{}
 
-	err := c.get("v1/symbols/"+strconv.Itoa(id)+"/options", &o, url.Values{})
+	err := c.get(ctx, "v1/symbols/"+strconv.Itoa(id)+"/options", &o, url.Values{})
 	if err != nil {
 		return []OptionChain{}, err
 	}
@@ -338,11 +691,16 @@ func (c *Client) GetOptionChain(id int) ([]OptionChain, error) {
 
 // GetMarkets retrieves information about supported markets
 func (c *Client) GetMarkets() ([]Market, error) {
+	return c.GetMarketsContext(context.Background())
+}
+
+// GetMarketsContext is like GetMarkets, but uses the provided context for the request.
+func (c *Client) GetMarketsContext(ctx context.Context) ([]Market, error) {
 	m := struct {
 		Markets []Market `json:"markets"`
 	}{}
 
-	err := c.get("v1/markets", &m, url.Values{})
+	err := c.get(ctx, "v1/markets", &m, url.Values{})
 	if err != nil {
 		return []Market{}, err
 	}
@@ -352,6 +710,11 @@ func (c *Client) GetMarkets() ([]Market, error) {
 // GetQuote retrieves a single Level 1 market data quote for a single symbol
 // TODO - Test
 func (c *Client) GetQuote(id int) (Quote, error) {
+	return c.GetQuoteContext(context.Background(), id)
+}
+
+// GetQuoteContext is like GetQuote, but uses the provided context for the request.
+func (c *Client) GetQuoteContext(ctx context.Context, id int) (Quote, error) {
 	idStr := strconv.Itoa(id)
 
 	q := struct {
@@ -359,7 +722,7 @@ func (c *Client) GetQuote(id int) (Quote, error) {
 	}{}
 	//var q2 json.RawMessage
 
-	err := c.get("v1/markets/quotes/"+idStr, &q, url.Values{})
+	err := c.get(ctx, "v1/markets/quotes/"+idStr, &q, url.Values{})
 	if err != nil {
 		return Quote{}, err
 	}
@@ -375,6 +738,11 @@ func (c *Client) GetQuote(id int) (Quote, error) {
 // GetQuotes retrieves a single Level 1 market data quote for many symbols
 // TODO - Test
 func (c *Client) GetQuotes(ids ...int) ([]Quote, error) {
+	return c.GetQuotesContext(context.Background(), ids...)
+}
+
+// GetQuotesContext is like GetQuotes, but uses the provided context for the request.
+func (c *Client) GetQuotesContext(ctx context.Context, ids ...int) ([]Quote, error) {
 	idStr := ""
 	for k, v := range ids {
 		idStr += strconv.Itoa(v)
@@ -390,7 +758,7 @@ func (c *Client) GetQuotes(ids ...int) ([]Quote, error) {
 		Quotes []Quote `json:"quotes"`
 	}{}
 
-	err := c.get("v1/markets/quotes?", &q, params)
+	err := c.get(ctx, "v1/markets/quotes", &q, params)
 	if err != nil {
 		return []Quote{}, err
 	}
@@ -398,10 +766,85 @@ func (c *Client) GetQuotes(ids ...int) ([]Quote, error) {
 	return q.Quotes, nil
 }
 
+// GetOptionQuotes retrieves quotes, including greeks, for the options with the given symbol ID's
+// See: http://www.questrade.com/api/documentation/rest-operations/market-calls/markets-quotes-options
+func (c *Client) GetOptionQuotes(ids ...int) ([]OptionQuote, error) {
+	return c.GetOptionQuotesContext(context.Background(), ids...)
+}
+
+// GetOptionQuotesContext is like GetOptionQuotes, but uses the provided context for the request.
+func (c *Client) GetOptionQuotesContext(ctx context.Context, ids ...int) ([]OptionQuote, error) {
+	req := struct {
+		OptionIDs []int `json:"optionIds"`
+	}{ids}
+
+	return c.getOptionQuotes(ctx, req)
+}
+
+// GetOptionQuotesByFilter retrieves quotes, including greeks, for the options matching
+// the filters.
+// See: http://www.questrade.com/api/documentation/rest-operations/market-calls/markets-quotes-options
+func (c *Client) GetOptionQuotesByFilter(filters ...OptionQuoteFilter) ([]OptionQuote, error) {
+	return c.GetOptionQuotesByFilterContext(context.Background(), filters...)
+}
+
+// GetOptionQuotesByFilterContext is like GetOptionQuotesByFilter, but uses the provided context for the request.
+func (c *Client) GetOptionQuotesByFilterContext(ctx context.Context, filters ...OptionQuoteFilter) ([]OptionQuote, error) {
+	req := struct {
+		Filters []OptionQuoteFilter `json:"filters"`
+	}{filters}
+
+	return c.getOptionQuotes(ctx, req)
+}
+
+// getOptionQuotes sends an option quote request
+func (c *Client) getOptionQuotes(ctx context.Context, req interface{}) ([]OptionQuote, error) {
+	q := struct {
+		OptionQuotes []OptionQuote `json:"optionQuotes"`
+	}{}
+
+	err := c.post(ctx, "v1/markets/quotes/options", &q, req)
+	if err != nil {
+		return []OptionQuote{}, err
+	}
+
+	return q.OptionQuotes, nil
+}
+
+// GetStrategyQuotes retrieves quotes for option strategies, with each variant quoted as
+// a single instrument.
+// See: http://www.questrade.com/api/documentation/rest-operations/market-calls/markets-quotes-strategies
+func (c *Client) GetStrategyQuotes(variants ...StrategyVariant) ([]StrategyQuote, error) {
+	return c.GetStrategyQuotesContext(context.Background(), variants...)
+}
+
+// GetStrategyQuotesContext is like GetStrategyQuotes, but uses the provided context for the request.
+func (c *Client) GetStrategyQuotesContext(ctx context.Context, variants ...StrategyVariant) ([]StrategyQuote, error) {
+	req := struct {
+		Variants []StrategyVariant `json:"variants"`
+	}{variants}
+
+	q := struct {
+		StrategyQuotes []StrategyQuote `json:"strategyQuotes"`
+	}{}
+
+	err := c.post(ctx, "v1/markets/quotes/strategies", &q, req)
+	if err != nil {
+		return []StrategyQuote{}, err
+	}
+
+	return q.StrategyQuotes, nil
+}
+
 // GetOrderImpact calculates the impact that a given order will have on an
 // account without placing it.
 // See: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-impact
 func (c *Client) GetOrderImpact(req OrderRequest) (OrderImpact, error) {
+	return c.GetOrderImpactContext(context.Background(), req)
+}
+
+// GetOrderImpactContext is like GetOrderImpact, but uses the provided context for the request.
+func (c *Client) GetOrderImpactContext(ctx context.Context, req OrderRequest) (OrderImpact, error) {
 	// Construct the endpoint - will be different if the impact is being calculated on
 	// an order that already exists
 	endpoint := fmt.Sprintf("v1/accounts/%s/orders/", req.AccountID)
@@ -411,7 +854,7 @@ func (c *Client) GetOrderImpact(req OrderRequest) (OrderImpact, error) {
 	endpoint += "impact"
 
 	var impact OrderImpact
-	err := c.post(endpoint, &impact, req)
+	err := c.post(ctx, endpoint, &impact, req)
 	if err != nil {
 		return OrderImpact{}, err
 	}
@@ -422,61 +865,246 @@ func (c *Client) GetOrderImpact(req OrderRequest) (OrderImpact, error) {
 // PlaceOrder submits an order request, or an update to an existing order to Questrade
 // See: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders
 func (c *Client) PlaceOrder(req OrderRequest) ([]Order, error) {
+	return c.PlaceOrderContext(context.Background(), req)
+}
+
+// PlaceOrderContext is like PlaceOrder, but uses the provided context for the request.
+func (c *Client) PlaceOrderContext(ctx context.Context, req OrderRequest) ([]Order, error) {
 	// Construct the endpoint
 	endpoint := fmt.Sprintf("v1/accounts/%s/orders/", req.AccountID)
 	if req.OrderID != 0 {
 		endpoint += fmt.Sprintf("%d", req.OrderID)
 	}
 
+	if c.validate {
+		if err := c.validateOrder(ctx, req); err != nil {
+			return []Order{}, err
+		}
+	}
+
 	res := struct {
 		OrderID int     `json:"orderId"`
 		Orders  []Order `json:"orders"`
 	}{}
 
-	err := c.post(endpoint, &res, req)
+	if c.strictEnums {
+		if err := checkEnums(req); err != nil {
+			return []Order{}, err
+		}
+	}
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return []Order{}, err
+	}
+
+	// Orders are only retried if the policy allows it, and never resubmitted if
+	// an earlier attempt may have reached the server.
+	policy := c.RetryPolicy
+	if !policy.RetryOrders {
+		policy.MaxAttempts = 1
+	}
+
+	since := time.Now().Add(-orderClockSkew)
+	// Identical orders that were placed before this one must not be mistaken for it
+	existing := map[int]bool{}
+	if policy.MaxAttempts > 1 {
+		orders, err := c.matchingOrders(ctx, req, since)
+		if err != nil {
+			policy.MaxAttempts = 1
+		}
+		for _, o := range orders {
+			existing[o.ID] = true
+		}
+	}
+
+	err = c.retry(ctx, policy, func(prev error) error {
+		if prev != nil && !isRateLimited(prev) {
+			orders, err := c.findSubmittedOrder(ctx, req, since, existing)
+			if err != nil {
+				return fmt.Errorf("Error: Order may have been placed after %v, but it could not be verified: %v", prev, err)
+			}
+			if len(orders) > 0 {
+				res.Orders = orders
+				return nil
+			}
+		}
+		return c.send(ctx, "POST", endpoint, url.Values{}, body, &res)
+	})
 	if err != nil {
 		return []Order{}, err
 	}
 	return res.Orders, nil
 }
 
-// DeleteOrder - Sends a delete request for the specified order
-// See: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-orderid
-func (c *Client) DeleteOrder(acctNum string, orderID int) error {
-	endpoint := fmt.Sprintf("accounts/%s/orders/%d", acctNum, orderID)
-	req, err := http.NewRequest("DELETE", c.Credentials.ApiServer+endpoint, nil)
+// validateOrder checks an order request before it is placed. If it has a price,
+// the symbol is retrieved to check the price against its tick size.
+func (c *Client) validateOrder(ctx context.Context, req OrderRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	if req.LimitPrice.IsZero() && req.StopPrice.IsZero() {
+		return nil
+	}
+
+	syms, err := c.GetSymbolsContext(ctx, req.SymbolID)
 	if err != nil {
 		return err
 	}
-	req.Header.Add("Authorization", c.Credentials.authHeader())
+	if len(syms) != 1 {
+		return errors.New("Error: Could not retreive symbol")
+	}
 
-	res, err := c.httpClient.Do(req)
+	return req.ValidateTicks(syms[0])
+}
 
-	out := struct {
-		OrderID int `json:"OrderID"`
+// GetStrategyImpact calculates the impact that a multi-leg strategy order will have on an
+// account without placing it.
+// See: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-strategy-impact
+func (c *Client) GetStrategyImpact(req StrategyOrderRequest) (OrderImpact, error) {
+	return c.GetStrategyImpactContext(context.Background(), req)
+}
+
+// GetStrategyImpactContext is like GetStrategyImpact, but uses the provided context for the request.
+func (c *Client) GetStrategyImpactContext(ctx context.Context, req StrategyOrderRequest) (OrderImpact, error) {
+	endpoint := fmt.Sprintf("v1/accounts/%s/orders/strategy/impact", req.AccountID)
+
+	var impact OrderImpact
+	err := c.post(ctx, endpoint, &impact, req)
+	if err != nil {
+		return OrderImpact{}, err
+	}
+
+	return impact, nil
+}
+
+// PlaceStrategyOrder submits a multi-leg strategy order, or an update to an existing one.
+// Strategy orders are never retried, regardless of the client's retry policy.
+// See: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-strategy
+func (c *Client) PlaceStrategyOrder(req StrategyOrderRequest) ([]Order, error) {
+	return c.PlaceStrategyOrderContext(context.Background(), req)
+}
+
+// PlaceStrategyOrderContext is like PlaceStrategyOrder, but uses the provided context for the request.
+func (c *Client) PlaceStrategyOrderContext(ctx context.Context, req StrategyOrderRequest) ([]Order, error) {
+	endpoint := fmt.Sprintf("v1/accounts/%s/orders/strategy", req.AccountID)
+
+	res := struct {
+		OrderID int     `json:"orderId"`
+		Orders  []Order `json:"orders"`
 	}{}
 
-	err = c.processResponse(res, out)
+	err := c.post(ctx, endpoint, &res, req)
 	if err != nil {
-		return err
+		return []Order{}, err
 	}
-	return nil
+	return res.Orders, nil
+}
+
+// GetBracketImpact calculates the impact that a bracket order will have on an
+// account without placing it.
+// See: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-bracket-impact
+func (c *Client) GetBracketImpact(req BracketOrderRequest) (OrderImpact, error) {
+	return c.GetBracketImpactContext(context.Background(), req)
+}
+
+// GetBracketImpactContext is like GetBracketImpact, but uses the provided context for the request.
+func (c *Client) GetBracketImpactContext(ctx context.Context, req BracketOrderRequest) (OrderImpact, error) {
+	endpoint := fmt.Sprintf("v1/accounts/%s/orders/bracket/impact", req.AccountID)
+
+	var impact OrderImpact
+	err := c.post(ctx, endpoint, &impact, req)
+	if err != nil {
+		return OrderImpact{}, err
+	}
+
+	return impact, nil
+}
+
+// PlaceBracketOrder submits a bracket order, and returns its primary, profit and loss
+// orders grouped together. Bracket orders are never retried, regardless of the
+// client's retry policy.
+// See: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-bracket
+func (c *Client) PlaceBracketOrder(req BracketOrderRequest) (BracketOrder, error) {
+	return c.PlaceBracketOrderContext(context.Background(), req)
+}
+
+// PlaceBracketOrderContext is like PlaceBracketOrder, but uses the provided context for the request.
+func (c *Client) PlaceBracketOrderContext(ctx context.Context, req BracketOrderRequest) (BracketOrder, error) {
+	endpoint := fmt.Sprintf("v1/accounts/%s/orders/bracket", req.AccountID)
+
+	res := struct {
+		Orders []Order `json:"orders"`
+	}{}
+
+	err := c.post(ctx, endpoint, &res, req)
+	if err != nil {
+		return BracketOrder{}, err
+	}
+
+	brackets := GroupBracketOrders(res.Orders)
+	if len(brackets) != 1 {
+		return BracketOrder{}, errors.New("Error: Could not retreive bracket orders")
+	}
+	return brackets[0], nil
+}
+
+// DeleteOrder - Sends a delete request for the specified order
+// See: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-orderid
+func (c *Client) DeleteOrder(acctNum string, orderID int) error {
+	return c.DeleteOrderContext(context.Background(), acctNum, orderID)
+}
+
+// DeleteOrderContext is like DeleteOrder, but uses the provided context for the request.
+func (c *Client) DeleteOrderContext(ctx context.Context, acctNum string, orderID int) error {
+	endpoint := fmt.Sprintf("v1/accounts/%s/orders/%d", acctNum, orderID)
+
+	out := struct {
+		OrderID int `json:"orderId"`
+	}{}
+
+	return c.do(ctx, "DELETE", endpoint, url.Values{}, nil, &out)
 }
 
 // GetCandles retrieves historical market data between the start and end dates,
-// in the given data granularity.
+// in the given data granularity. Candles that start at or after the start date and
+// before the end date are returned. If the client has a candle cache, only the
+// parts of the range that aren't cached are retrieved - see WithCandleCache.
 // See: http://www.questrade.com/api/documentation/rest-operations/market-calls/markets-candles-id
-func (c *Client) GetCandles(id int, start time.Time, end time.Time, interval string) ([]Candlestick, error) {
+func (c *Client) GetCandles(id int, start time.Time, end time.Time, interval CandleInterval) ([]Candlestick, error) {
+	return c.GetCandlesContext(context.Background(), id, start, end, interval)
+}
+
+// GetCandlesContext is like GetCandles, but uses the provided context for the request.
+func (c *Client) GetCandlesContext(ctx context.Context, id int, start time.Time, end time.Time, interval CandleInterval) ([]Candlestick, error) {
+	if !interval.Valid() {
+		return []Candlestick{}, fmt.Errorf("Error: Unknown candle interval %s", interval)
+	}
+
+	if c.candleCache != nil {
+		return c.cachedCandles(ctx, id, start, end, interval)
+	}
+
+	candles, err := c.getCandles(ctx, id, start, end, interval)
+	if err != nil {
+		return candles, err
+	}
+	return candlesIn(candles, TimeRange{start, end}), nil
+}
+
+// getCandles retrieves candles from the API with a single request
+func (c *Client) getCandles(ctx context.Context, id int, start time.Time, end time.Time, interval CandleInterval) ([]Candlestick, error) {
 	params := url.Values{}
 	params.Add("startTime", start.Format(time.RFC3339))
 	params.Add("endTime", end.Format(time.RFC3339))
-	params.Add("interval", interval)
+	params.Add("interval", string(interval))
 
 	r := struct {
-		Candles []Candlestick `json:candles`
+		Candles []Candlestick `json:"candles"`
 	}{}
 
-	err := c.get("v1/markets/candles/"+strconv.Itoa(id)+"?", &r, params)
+	err := c.get(ctx, "v1/markets/candles/"+strconv.Itoa(id), &r, params)
 	if err != nil {
 		return []Candlestick{}, err
 	}
@@ -484,29 +1112,82 @@ func (c *Client) GetCandles(id int, start time.Time, end time.Time, interval str
 }
 
 // NewClient is the factory function for clients - takes a refresh token and logs into
-// either the practice or live server.
-func NewClient(refreshToken string, practice bool) (*Client, error) {
-	transport := &http.Transport{
-		ResponseHeaderTimeout: 5 * time.Second,
+// either the practice or live server. Options can be given to customize the client,
+// see ClientOption.
+func NewClient(refreshToken string, practice bool, opts ...ClientOption) (*Client, error) {
+	c := newClient(LoginCredentials{RefreshToken: refreshToken}, practice, opts)
+
+	// A token given by the caller is newer than a stored one, such as after the
+	// application is authorized again
+	c.ownToken = c.Credentials.RefreshToken != ""
+
+	if !c.skipLogin {
+		err := c.Login(practice)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// NewClientFromStore creates a client that reads its credentials from the token
+// store, and saves the rotated credentials back to it on every login. If the
+// WithoutLogin option is given, the access token from the store is used as-is
+// until the server rejects it.
+func NewClientFromStore(store TokenStore, practice bool, opts ...ClientOption) (*Client, error) {
+	creds, err := store.Load()
+	if err != nil {
+		return nil, err
 	}
 
-	client := &http.Client{
-		Transport: transport,
+	if creds.RefreshToken == "" {
+		return nil, errors.New("Error: Token store does not contain a refresh token")
 	}
 
-	// Create a new client
+	c := newClient(creds, practice, append([]ClientOption{WithTokenStore(store)}, opts...))
+
+	if !c.skipLogin {
+		err = c.Login(practice)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+// newClient creates a client that has not logged in yet, and applies the options to it
+func newClient(creds LoginCredentials, practice bool, opts []ClientOption) *Client {
 	c := &Client{
-		Credentials: LoginCredentials{
-			RefreshToken: refreshToken,
-		},
-		httpClient: client,
-		transport:  transport,
+		Credentials: creds,
+		RetryPolicy: DefaultRetryPolicy,
+		practice:    practice,
+		loginLock:   make(chan struct{}, 1),
 	}
 
-	err := c.Login(practice)
-	if err != nil {
-		return nil, err
+	for _, opt := range opts {
+		opt(c)
 	}
 
-	return c, nil
+	if c.httpClient == nil {
+		c.transport = &http.Transport{
+			ResponseHeaderTimeout: 5 * time.Second,
+		}
+		c.httpClient = &http.Client{
+			Transport: c.transport,
+			Timeout:   c.timeout,
+		}
+	} else if c.timeout != 0 {
+		// Don't modify the caller's client
+		hc := *c.httpClient
+		hc.Timeout = c.timeout
+		c.httpClient = &hc
+	}
+
+	if c.apiServer != "" {
+		c.Credentials.ApiServer = c.apiServer
+	}
+
+	return c
 }
@@ -0,0 +1,40 @@
+package qapi_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/qapitest"
+)
+
+func TestGetActivitiesRejectsInvalidRange(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(qapi.Account{Number: "12345678", Type: qapi.AccountMargin})
+
+	c, err := srv.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	sent := len(srv.Requests())
+
+	now := time.Now()
+	ranges := []struct {
+		name       string
+		start, end time.Time
+	}{
+		{"zero start", time.Time{}, now},
+		{"zero end", now, time.Time{}},
+		{"inverted", now, now.Add(-time.Hour)},
+	}
+	for _, r := range ranges {
+		if _, err := c.GetActivities("12345678", r.start, r.end); err == nil {
+			t.Errorf("GetActivities with %s range: expected an error", r.name)
+		}
+	}
+
+	if n := len(srv.Requests()) - sent; n != 0 {
+		t.Errorf("%d requests sent for invalid ranges, want 0", n)
+	}
+}
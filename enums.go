@@ -0,0 +1,777 @@
+package qapi
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// enum is implemented by the enumerated types, whose values are checked by
+// clients created with the WithStrictEnums option.
+//
+// Ref: http://www.questrade.com/api/documentation/rest-operations/enumerations/enumerations
+type enum interface {
+	Valid() bool
+}
+
+// checkEnums returns an error if v holds an enumerated value, at any depth, that
+// is not documented by Questrade. Empty values are always accepted.
+func checkEnums(v interface{}) error {
+	return checkEnumValue(reflect.ValueOf(v))
+}
+
+// checkEnumValue checks the enumerated values held by v
+func checkEnumValue(v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.String:
+		if e, ok := v.Interface().(enum); ok && v.Len() > 0 && !e.Valid() {
+			return fmt.Errorf("Error: Unknown %s %q", v.Type().Name(), v.String())
+		}
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			return checkEnumValue(v.Elem())
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !v.Type().Field(i).IsExported() {
+				continue
+			}
+			if err := checkEnumValue(v.Field(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkEnumValue(v.Index(i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := checkEnumValue(iter.Value()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Currency is an ISO currency code.
+type Currency string
+
+const (
+	// Canadian dollar.
+	CAD Currency = "CAD"
+
+	// US dollar.
+	USD Currency = "USD"
+)
+
+var validCurrencyValues = map[Currency]bool{
+	CAD: true,
+	USD: true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (c Currency) Valid() bool {
+	return validCurrencyValues[c]
+}
+
+// ListingExchange is the code of an exchange on which securities are listed.
+type ListingExchange string
+
+const (
+	// Toronto Stock Exchange.
+	ExchangeTSX ListingExchange = "TSX"
+
+	// Toronto Venture Exchange.
+	ExchangeTSXV ListingExchange = "TSXV"
+
+	// Canadian National Stock Exchange.
+	ExchangeCNSX ListingExchange = "CNSX"
+
+	// Montreal Exchange.
+	ExchangeMX ListingExchange = "MX"
+
+	// NASDAQ.
+	ExchangeNASDAQ ListingExchange = "NASDAQ"
+
+	// New York Stock Exchange.
+	ExchangeNYSE ListingExchange = "NYSE"
+
+	// NYSE American.
+	ExchangeNYSEAM ListingExchange = "NYSEAM"
+
+	// NYSE Arca.
+	ExchangeARCA ListingExchange = "ARCA"
+
+	// Option Reporting Authority.
+	ExchangeOPRA ListingExchange = "OPRA"
+
+	// Pink Sheets.
+	ExchangePinkSheets ListingExchange = "PinkSheets"
+
+	// OTC Bulletin Board.
+	ExchangeOTCBB ListingExchange = "OTCBB"
+)
+
+var validListingExchangeValues = map[ListingExchange]bool{
+	ExchangeTSX:        true,
+	ExchangeTSXV:       true,
+	ExchangeCNSX:       true,
+	ExchangeMX:         true,
+	ExchangeNASDAQ:     true,
+	ExchangeNYSE:       true,
+	ExchangeNYSEAM:     true,
+	ExchangeARCA:       true,
+	ExchangeOPRA:       true,
+	ExchangePinkSheets: true,
+	ExchangeOTCBB:      true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (l ListingExchange) Valid() bool {
+	return validListingExchangeValues[l]
+}
+
+// AccountType is the type of an account.
+type AccountType string
+
+const (
+	// Cash account.
+	AccountCash AccountType = "Cash"
+
+	// Margin account.
+	AccountMargin AccountType = "Margin"
+
+	// Tax Free Savings Account.
+	AccountTFSA AccountType = "TFSA"
+
+	// Registered Retirement Savings Plan.
+	AccountRRSP AccountType = "RRSP"
+
+	// Spousal RRSP.
+	AccountSRRSP AccountType = "SRRSP"
+
+	// Locked-In RRSP.
+	AccountLRRSP AccountType = "LRRSP"
+
+	// Locked-In Retirement Account.
+	AccountLIRA AccountType = "LIRA"
+
+	// Life Income Fund.
+	AccountLIF AccountType = "LIF"
+
+	// Retirement Income Fund.
+	AccountRIF AccountType = "RIF"
+
+	// Spousal RIF.
+	AccountSRIF AccountType = "SRIF"
+
+	// Locked-In RIF.
+	AccountLRIF AccountType = "LRIF"
+
+	// Registered RIF.
+	AccountRRIF AccountType = "RRIF"
+
+	// Prescribed RIF.
+	AccountPRIF AccountType = "PRIF"
+
+	// Individual Registered Education Savings Plan.
+	AccountRESP AccountType = "RESP"
+
+	// Family RESP.
+	AccountFRESP AccountType = "FRESP"
+)
+
+var validAccountTypeValues = map[AccountType]bool{
+	AccountCash:   true,
+	AccountMargin: true,
+	AccountTFSA:   true,
+	AccountRRSP:   true,
+	AccountSRRSP:  true,
+	AccountLRRSP:  true,
+	AccountLIRA:   true,
+	AccountLIF:    true,
+	AccountRIF:    true,
+	AccountSRIF:   true,
+	AccountLRIF:   true,
+	AccountRRIF:   true,
+	AccountPRIF:   true,
+	AccountRESP:   true,
+	AccountFRESP:  true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (a AccountType) Valid() bool {
+	return validAccountTypeValues[a]
+}
+
+// ClientAccountType is the type of client holding an account.
+type ClientAccountType string
+
+const (
+	// Account held by an individual.
+	ClientIndividual ClientAccountType = "Individual"
+
+	// Account held jointly by several individuals (e.g., spouses).
+	ClientJoint ClientAccountType = "Joint"
+
+	// Non-individual account held by an informal trust.
+	ClientInformalTrust ClientAccountType = "Informal Trust"
+
+	// Non-individual account held by a corporation.
+	ClientCorporation ClientAccountType = "Corporation"
+
+	// Non-individual account held by an investment club.
+	ClientInvestmentClub ClientAccountType = "Investment Club"
+
+	// Non-individual account held by a formal trust.
+	ClientFormalTrust ClientAccountType = "Formal Trust"
+
+	// Non-individual account held by a partnership.
+	ClientPartnership ClientAccountType = "Partnership"
+
+	// Non-individual account held by a sole proprietorship.
+	ClientSoleProprietorship ClientAccountType = "Sole Proprietorship"
+
+	// Account held by a family.
+	ClientFamily ClientAccountType = "Family"
+
+	// Non-individual account held by a joint and informal trust.
+	ClientJointAndInformalTrust ClientAccountType = "Joint and Informal Trust"
+
+	// Non-individual account held by an institution.
+	ClientInstitution ClientAccountType = "Institution"
+)
+
+var validClientAccountTypeValues = map[ClientAccountType]bool{
+	ClientIndividual:            true,
+	ClientJoint:                 true,
+	ClientInformalTrust:         true,
+	ClientCorporation:           true,
+	ClientInvestmentClub:        true,
+	ClientFormalTrust:           true,
+	ClientPartnership:           true,
+	ClientSoleProprietorship:    true,
+	ClientFamily:                true,
+	ClientJointAndInformalTrust: true,
+	ClientInstitution:           true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (c ClientAccountType) Valid() bool {
+	return validClientAccountTypeValues[c]
+}
+
+// AccountStatus is the status of an account.
+type AccountStatus string
+
+const (
+	AccountActive            AccountStatus = "Active"
+	AccountSuspendedClosed   AccountStatus = "Suspended (Closed)"
+	AccountSuspendedViewOnly AccountStatus = "Suspended (View Only)"
+	AccountLiquidateOnly     AccountStatus = "Liquidate Only"
+	AccountClosed            AccountStatus = "Closed"
+)
+
+var validAccountStatusValues = map[AccountStatus]bool{
+	AccountActive:            true,
+	AccountSuspendedClosed:   true,
+	AccountSuspendedViewOnly: true,
+	AccountLiquidateOnly:     true,
+	AccountClosed:            true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (a AccountStatus) Valid() bool {
+	return validAccountStatusValues[a]
+}
+
+// TickType is the direction of the last trade relative to the one before it.
+type TickType string
+
+const (
+	// Designates an uptick.
+	TickUp TickType = "Up"
+
+	// Designates a downtick.
+	TickDown TickType = "Down"
+
+	// Designates a trade at the same price as the previous one.
+	TickEqual TickType = "Equal"
+)
+
+var validTickTypeValues = map[TickType]bool{
+	TickUp:    true,
+	TickDown:  true,
+	TickEqual: true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (t TickType) Valid() bool {
+	return validTickTypeValues[t]
+}
+
+// OptionType is the type of an option contract.
+type OptionType string
+
+const (
+	// Call option.
+	OptionCall OptionType = "Call"
+
+	// Put option.
+	OptionPut OptionType = "Put"
+)
+
+var validOptionTypeValues = map[OptionType]bool{
+	OptionCall: true,
+	OptionPut:  true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (o OptionType) Valid() bool {
+	return validOptionTypeValues[o]
+}
+
+// OptionDurationType is the length of an option contract's expiry cycle.
+type OptionDurationType string
+
+const (
+	// Weekly expiry cycle.
+	OptionWeekly OptionDurationType = "Weekly"
+
+	// Monthly expiry cycle.
+	OptionMonthly OptionDurationType = "Monthly"
+
+	// Quarterly expiry cycle.
+	OptionQuarterly OptionDurationType = "Quarterly"
+
+	// Long-term Equity Appreciation contracts.
+	OptionLEAP OptionDurationType = "LEAP"
+)
+
+var validOptionDurationTypeValues = map[OptionDurationType]bool{
+	OptionWeekly:    true,
+	OptionMonthly:   true,
+	OptionQuarterly: true,
+	OptionLEAP:      true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (o OptionDurationType) Valid() bool {
+	return validOptionDurationTypeValues[o]
+}
+
+// OptionExerciseType is the exercise style of an option contract.
+type OptionExerciseType string
+
+const (
+	// American option.
+	OptionAmerican OptionExerciseType = "American"
+
+	// European option.
+	OptionEuropean OptionExerciseType = "European"
+)
+
+var validOptionExerciseTypeValues = map[OptionExerciseType]bool{
+	OptionAmerican: true,
+	OptionEuropean: true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (o OptionExerciseType) Valid() bool {
+	return validOptionExerciseTypeValues[o]
+}
+
+// SecurityType is the type of a security.
+type SecurityType string
+
+const (
+	// Common and preferred equities, ETFs, ETNs, units, ADRs, etc.
+	SecurityStock SecurityType = "Stock"
+
+	// Equity and index options.
+	SecurityOption SecurityType = "Option"
+
+	// Debentures, notes, bonds, both corporate and government.
+	SecurityBond SecurityType = "Bond"
+
+	// Equity or bond rights and warrants.
+	SecurityRight SecurityType = "Right"
+
+	// Physical gold (coins, wafers, bars).
+	SecurityGold SecurityType = "Gold"
+
+	// Canadian or US mutual funds.
+	SecurityMutualFund SecurityType = "MutualFund"
+
+	// Stock indices (e.g., Dow Jones).
+	SecurityIndex SecurityType = "Index"
+)
+
+var validSecurityTypeValues = map[SecurityType]bool{
+	SecurityStock:      true,
+	SecurityOption:     true,
+	SecurityBond:       true,
+	SecurityRight:      true,
+	SecurityGold:       true,
+	SecurityMutualFund: true,
+	SecurityIndex:      true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (s SecurityType) Valid() bool {
+	return validSecurityTypeValues[s]
+}
+
+// OrderStateFilter selects orders by state when listing them.
+type OrderStateFilter string
+
+const (
+	// Includes all orders, regardless of their state.
+	OrderFilterAll OrderStateFilter = "All"
+
+	// Includes only orders that are still open.
+	OrderFilterOpen OrderStateFilter = "Open"
+
+	// Includes only orders that are already closed.
+	OrderFilterClosed OrderStateFilter = "Closed"
+)
+
+var validOrderStateFilterValues = map[OrderStateFilter]bool{
+	OrderFilterAll:    true,
+	OrderFilterOpen:   true,
+	OrderFilterClosed: true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (o OrderStateFilter) Valid() bool {
+	return validOrderStateFilterValues[o]
+}
+
+// OrderSide is the side of an order or execution.
+type OrderSide string
+
+const (
+	// Buy.
+	SideBuy OrderSide = "Buy"
+
+	// Sell.
+	SideSell OrderSide = "Sell"
+
+	// Sell short.
+	SideShort OrderSide = "Short"
+
+	// Cover the short.
+	SideCov OrderSide = "Cov"
+
+	// Buy-To-Open.
+	SideBTO OrderSide = "BTO"
+
+	// Sell-To-Close.
+	SideSTC OrderSide = "STC"
+
+	// Sell-To-Open.
+	SideSTO OrderSide = "STO"
+
+	// Buy-To-Close.
+	SideBTC OrderSide = "BTC"
+)
+
+var validOrderSideValues = map[OrderSide]bool{
+	SideBuy:   true,
+	SideSell:  true,
+	SideShort: true,
+	SideCov:   true,
+	SideBTO:   true,
+	SideSTC:   true,
+	SideSTO:   true,
+	SideBTC:   true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (o OrderSide) Valid() bool {
+	return validOrderSideValues[o]
+}
+
+// IsBuy determines whether the side buys securities, opening or adding to a long
+// position or covering a short one.
+func (o OrderSide) IsBuy() bool {
+	switch o {
+	case SideBuy, SideCov, SideBTO, SideBTC:
+		return true
+	}
+	return false
+}
+
+// OrderRoute is the code of a venue an order is routed to. The routes available
+// in each market are listed by GetMarkets, so unlike the other enumerations,
+// strict mode only rejects routes that are not well-formed.
+type OrderRoute string
+
+const (
+	// Let Questrade choose the best route.
+	RouteAuto OrderRoute = "AUTO"
+)
+
+// Valid reports whether the value is a well-formed route code, made of
+// uppercase letters and digits
+func (o OrderRoute) Valid() bool {
+	if o == "" {
+		return false
+	}
+	for _, r := range o {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// OrderType is the price type of an order.
+type OrderType string
+
+const (
+	OrderTypeMarket                     OrderType = "Market"
+	OrderTypeLimit                      OrderType = "Limit"
+	OrderTypeStop                       OrderType = "Stop"
+	OrderTypeStopLimit                  OrderType = "StopLimit"
+	OrderTypeTrailStopInPercentage      OrderType = "TrailStopInPercentage"
+	OrderTypeTrailStopInDollar          OrderType = "TrailStopInDollar"
+	OrderTypeTrailStopLimitInPercentage OrderType = "TrailStopLimitInPercentage"
+	OrderTypeTrailStopLimitInDollar     OrderType = "TrailStopLimitInDollar"
+	OrderTypeLimitOnOpen                OrderType = "LimitOnOpen"
+	OrderTypeLimitOnClose               OrderType = "LimitOnClose"
+)
+
+var validOrderTypeValues = map[OrderType]bool{
+	OrderTypeMarket:                     true,
+	OrderTypeLimit:                      true,
+	OrderTypeStop:                       true,
+	OrderTypeStopLimit:                  true,
+	OrderTypeTrailStopInPercentage:      true,
+	OrderTypeTrailStopInDollar:          true,
+	OrderTypeTrailStopLimitInPercentage: true,
+	OrderTypeTrailStopLimitInDollar:     true,
+	OrderTypeLimitOnOpen:                true,
+	OrderTypeLimitOnClose:               true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (o OrderType) Valid() bool {
+	return validOrderTypeValues[o]
+}
+
+// TimeInForce is the length of time an order remains active.
+type TimeInForce string
+
+const (
+	TimeInForceDay                 TimeInForce = "Day"
+	TimeInForceGoodTillCanceled    TimeInForce = "GoodTillCanceled"
+	TimeInForceGoodTillExtendedDay TimeInForce = "GoodTillExtendedDay"
+	TimeInForceGoodTillDate        TimeInForce = "GoodTillDate"
+	TimeInForceImmediateOrCancel   TimeInForce = "ImmediateOrCancel"
+	TimeInForceFillOrKill          TimeInForce = "FillOrKill"
+)
+
+var validTimeInForceValues = map[TimeInForce]bool{
+	TimeInForceDay:                 true,
+	TimeInForceGoodTillCanceled:    true,
+	TimeInForceGoodTillExtendedDay: true,
+	TimeInForceGoodTillDate:        true,
+	TimeInForceImmediateOrCancel:   true,
+	TimeInForceFillOrKill:          true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (t TimeInForce) Valid() bool {
+	return validTimeInForceValues[t]
+}
+
+// OrderState is the state of an order.
+type OrderState string
+
+const (
+	OrderStateFailed            OrderState = "Failed"
+	OrderStatePending           OrderState = "Pending"
+	OrderStateAccepted          OrderState = "Accepted"
+	OrderStateRejected          OrderState = "Rejected"
+	OrderStateCancelPending     OrderState = "CancelPending"
+	OrderStateCanceled          OrderState = "Canceled"
+	OrderStatePartialCanceled   OrderState = "PartialCanceled"
+	OrderStatePartial           OrderState = "Partial"
+	OrderStateExecuted          OrderState = "Executed"
+	OrderStateReplacePending    OrderState = "ReplacePending"
+	OrderStateReplaced          OrderState = "Replaced"
+	OrderStateStopped           OrderState = "Stopped"
+	OrderStateSuspended         OrderState = "Suspended"
+	OrderStateExpired           OrderState = "Expired"
+	OrderStateQueued            OrderState = "Queued"
+	OrderStateTriggered         OrderState = "Triggered"
+	OrderStateActivated         OrderState = "Activated"
+	OrderStatePendingRiskReview OrderState = "PendingRiskReview"
+	OrderStateContingentOrder   OrderState = "ContingentOrder"
+)
+
+var validOrderStateValues = map[OrderState]bool{
+	OrderStateFailed:            true,
+	OrderStatePending:           true,
+	OrderStateAccepted:          true,
+	OrderStateRejected:          true,
+	OrderStateCancelPending:     true,
+	OrderStateCanceled:          true,
+	OrderStatePartialCanceled:   true,
+	OrderStatePartial:           true,
+	OrderStateExecuted:          true,
+	OrderStateReplacePending:    true,
+	OrderStateReplaced:          true,
+	OrderStateStopped:           true,
+	OrderStateSuspended:         true,
+	OrderStateExpired:           true,
+	OrderStateQueued:            true,
+	OrderStateTriggered:         true,
+	OrderStateActivated:         true,
+	OrderStatePendingRiskReview: true,
+	OrderStateContingentOrder:   true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (o OrderState) Valid() bool {
+	return validOrderStateValues[o]
+}
+
+// OrderClass is the role of an order within a bracket order.
+type OrderClass string
+
+const (
+	// Primary order.
+	OrderClassPrimary OrderClass = "Primary"
+
+	// Profit exit order.
+	OrderClassProfit OrderClass = "Profit"
+
+	// Loss exit order.
+	OrderClassLoss OrderClass = "Loss"
+)
+
+var validOrderClassValues = map[OrderClass]bool{
+	OrderClassPrimary: true,
+	OrderClassProfit:  true,
+	OrderClassLoss:    true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (o OrderClass) Valid() bool {
+	return validOrderClassValues[o]
+}
+
+// StrategyType is the type of a multi-leg strategy.
+type StrategyType string
+
+const (
+	StrategyCoveredCall        StrategyType = "CoveredCall"
+	StrategyMarriedPuts        StrategyType = "MarriedPuts"
+	StrategyVerticalCallSpread StrategyType = "VerticalCallSpread"
+	StrategyVerticalPutSpread  StrategyType = "VerticalPutSpread"
+	StrategyCalendarCallSpread StrategyType = "CalendarCallSpread"
+	StrategyCalendarPutSpread  StrategyType = "CalendarPutSpread"
+	StrategyDiagonalCallSpread StrategyType = "DiagonalCallSpread"
+	StrategyDiagonalPutSpread  StrategyType = "DiagonalPutSpread"
+	StrategyCollar             StrategyType = "Collar"
+	StrategyStraddle           StrategyType = "Straddle"
+	StrategyStrangle           StrategyType = "Strangle"
+	StrategyButterflyCall      StrategyType = "ButterflyCall"
+	StrategyButterflyPut       StrategyType = "ButterflyPut"
+	StrategyIronButterfly      StrategyType = "IronButterfly"
+	StrategyCondorCall         StrategyType = "CondorCall"
+	StrategyCustom             StrategyType = "Custom"
+)
+
+var validStrategyTypeValues = map[StrategyType]bool{
+	StrategyCoveredCall:        true,
+	StrategyMarriedPuts:        true,
+	StrategyVerticalCallSpread: true,
+	StrategyVerticalPutSpread:  true,
+	StrategyCalendarCallSpread: true,
+	StrategyCalendarPutSpread:  true,
+	StrategyDiagonalCallSpread: true,
+	StrategyDiagonalPutSpread:  true,
+	StrategyCollar:             true,
+	StrategyStraddle:           true,
+	StrategyStrangle:           true,
+	StrategyButterflyCall:      true,
+	StrategyButterflyPut:       true,
+	StrategyIronButterfly:      true,
+	StrategyCondorCall:         true,
+	StrategyCustom:             true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (s StrategyType) Valid() bool {
+	return validStrategyTypeValues[s]
+}
+
+// ActivityType is the category of an account activity.
+type ActivityType string
+
+const (
+	ActivityTrades               ActivityType = "Trades"
+	ActivityDividends            ActivityType = "Dividends"
+	ActivityDeposits             ActivityType = "Deposits"
+	ActivityWithdrawals          ActivityType = "Withdrawals"
+	ActivityTransfers            ActivityType = "Transfers"
+	ActivityInterest             ActivityType = "Interest"
+	ActivityFXConversion         ActivityType = "FX conversion"
+	ActivityFeesAndRebates       ActivityType = "Fees and rebates"
+	ActivityCorporateActions     ActivityType = "Corporate actions"
+	ActivityDividendReinvestment ActivityType = "Dividend reinvestment"
+	ActivityOther                ActivityType = "Other"
+)
+
+var validActivityTypeValues = map[ActivityType]bool{
+	ActivityTrades:               true,
+	ActivityDividends:            true,
+	ActivityDeposits:             true,
+	ActivityWithdrawals:          true,
+	ActivityTransfers:            true,
+	ActivityInterest:             true,
+	ActivityFXConversion:         true,
+	ActivityFeesAndRebates:       true,
+	ActivityCorporateActions:     true,
+	ActivityDividendReinvestment: true,
+	ActivityOther:                true,
+}
+
+// Valid reports whether the value is one documented by Questrade
+func (a ActivityType) Valid() bool {
+	return validActivityTypeValues[a]
+}
+
+// CandleInterval is the length of the candles returned by GetCandles.
+type CandleInterval string
+
+const (
+	IntervalOneMinute      CandleInterval = "OneMinute"
+	IntervalTwoMinutes     CandleInterval = "TwoMinutes"
+	IntervalThreeMinutes   CandleInterval = "ThreeMinutes"
+	IntervalFourMinutes    CandleInterval = "FourMinutes"
+	IntervalFiveMinutes    CandleInterval = "FiveMinutes"
+	IntervalTenMinutes     CandleInterval = "TenMinutes"
+	IntervalFifteenMinutes CandleInterval = "FifteenMinutes"
+	IntervalTwentyMinutes  CandleInterval = "TwentyMinutes"
+	IntervalHalfHour       CandleInterval = "HalfHour"
+	IntervalOneHour        CandleInterval = "OneHour"
+	IntervalTwoHours       CandleInterval = "TwoHours"
+	IntervalFourHours      CandleInterval = "FourHours"
+	IntervalOneDay         CandleInterval = "OneDay"
+	IntervalOneWeek        CandleInterval = "OneWeek"
+	IntervalOneMonth       CandleInterval = "OneMonth"
+	IntervalOneYear        CandleInterval = "OneYear"
+)
+
+// Valid reports whether the value is one documented by Questrade
+func (c CandleInterval) Valid() bool {
+	_, ok := candleIntervals[c]
+	return ok
+}
@@ -0,0 +1,87 @@
+package qapi
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStrictEnumsIsPerClient(t *testing.T) {
+	data := []byte(`{"accounts":[{"type":"Undocumented","number":"12345678"}]}`)
+	res := struct {
+		Accounts []Account `json:"accounts"`
+	}{}
+
+	lenient := &Client{}
+	if err := lenient.decode(data, &res); err != nil || res.Accounts[0].Type != "Undocumented" {
+		t.Errorf("lenient decode = %+v, %v", res.Accounts, err)
+	}
+
+	strict := &Client{strictEnums: true}
+	if err := strict.decode(data, &res); err == nil || !strings.Contains(err.Error(), "AccountType") {
+		t.Errorf("strict decode = %v, want an unknown AccountType error", err)
+	}
+
+	// The order is rejected before anything is sent - the client has no HTTP
+	// client to send it with
+	_, err := strict.PlaceOrder(OrderRequest{
+		AccountID:   "12345678",
+		SymbolID:    8049,
+		Quantity:    1,
+		OrderType:   "Undocumented",
+		TimeInForce: TimeInForceDay,
+		Action:      SideBuy,
+	})
+	if err == nil || !strings.Contains(err.Error(), "OrderType") {
+		t.Errorf("strict PlaceOrder = %v, want an unknown OrderType error", err)
+	}
+}
+
+func TestCheckEnums(t *testing.T) {
+	if err := checkEnums(OrderRequest{AccountID: "12345678"}); err != nil {
+		t.Errorf("checkEnums of empty values = %v", err)
+	}
+	if err := checkEnums(&struct{ Orders []Order }{Orders: []Order{{Side: SideSell}}}); err != nil {
+		t.Errorf("checkEnums of documented values = %v", err)
+	}
+	if err := checkEnums(map[string]OrderState{"x": "Undocumented"}); err == nil {
+		t.Error("checkEnums of an undocumented value in a map: expected an error")
+	}
+}
+
+func TestOrderSideIsBuy(t *testing.T) {
+	buys := map[OrderSide]bool{
+		SideBuy:   true,
+		SideSell:  false,
+		SideShort: false,
+		SideCov:   true,
+		SideBTO:   true,
+		SideSTC:   false,
+		SideSTO:   false,
+		SideBTC:   true,
+	}
+	for side, want := range buys {
+		if got := side.IsBuy(); got != want {
+			t.Errorf("%s.IsBuy() = %v, want %v", side, got, want)
+		}
+	}
+}
+
+func TestOrderRouteValid(t *testing.T) {
+	routes := map[OrderRoute]bool{
+		RouteAuto: true,
+		"LAMP":    true,
+		"NYSE2":   true,
+		"":        false,
+		"auto":    false,
+		"ARCA ":   false,
+	}
+	for route, want := range routes {
+		if got := route.Valid(); got != want {
+			t.Errorf("%q.Valid() = %v, want %v", route, got, want)
+		}
+	}
+
+	if err := checkEnums(OrderRequest{PrimaryRoute: "auto"}); err == nil || !strings.Contains(err.Error(), "OrderRoute") {
+		t.Errorf("checkEnums of a malformed route = %v, want an unknown OrderRoute error", err)
+	}
+}
@@ -4,15 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 )
 
 type QuestradeError struct {
-	Code       int `json:"code",string`
+	Code       int `json:"code"`
 	StatusCode int
 	Message    string `json:"message"`
 	Endpoint   string
 	OrderId    int     `json:"orderId,omitempty"`
 	Orders     []Order `json:"orders,omitempty"`
+
+	// How long the server asked the client to wait before retrying, if at all.
+	RetryAfter time.Duration `json:"-"`
 }
 
 func newQuestradeError(res *http.Response, body []byte) QuestradeError {
@@ -27,9 +32,27 @@ func newQuestradeError(res *http.Response, body []byte) QuestradeError {
 	e.StatusCode = res.StatusCode
 	e.Endpoint = res.Request.URL.String()
 
+	if secs, err := strconv.Atoi(res.Header.Get("Retry-After")); err == nil {
+		e.RetryAfter = time.Duration(secs) * time.Second
+	}
+
 	return e
 }
 
+// OrderValidationError describes a problem with an order request that was found
+// before it was sent to Questrade.
+type OrderValidationError struct {
+	// Name of the offending OrderRequest field.
+	Field string
+
+	// Description of the problem.
+	Message string
+}
+
+func (o OrderValidationError) Error() string {
+	return fmt.Sprintf("Error: Invalid order %s: %s", o.Field, o.Message)
+}
+
 func (q QuestradeError) Error() string {
 	return fmt.Sprintf("\nQuestradeError:\n" +
 	                   "\tStatus code: HTTP %d\n" +
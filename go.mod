@@ -0,0 +1,3 @@
+module github.com/alexurquhart/qapi
+
+go 1.24
@@ -0,0 +1,143 @@
+// Package indicators calculates technical indicators over candlestick series.
+//
+// Every indicator can be calculated in two ways. The Series functions (e.g.,
+// SMASeries) take a whole series of candles, and return one value per candle.
+// Values that fall in the indicator's warm-up period, before it has seen enough
+// candles, are NaN. The indicator types (e.g., SMA) are updated one value or
+// candle at a time as new bars arrive, and report whether they have warmed up:
+//
+//	sma := indicators.NewSMA(20)
+//	for c := range bars {
+//		if v, ok := sma.Update(c.Close.Float64()); ok {
+//			fmt.Printf("SMA(20): %.2f\n", v)
+//		}
+//	}
+//
+// Both give the same values, since the Series functions are built on the types.
+// Indicators of a single price use the close of each candle.
+package indicators
+
+import (
+	"errors"
+	"math"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// Closes returns the closing prices of candles.
+func Closes(candles []qapi.Candlestick) []float64 {
+	closes := make([]float64, len(candles))
+	for k, c := range candles {
+		closes[k] = c.Close.Float64()
+	}
+	return closes
+}
+
+// checkPeriod panics if an indicator's period is less than one
+func checkPeriod(period int) {
+	if period < 1 {
+		panic(errors.New("Error: Indicator period must be at least 1"))
+	}
+}
+
+// valueOrNaN returns v if ok is true, or NaN otherwise
+func valueOrNaN(v float64, ok bool) float64 {
+	if !ok {
+		return math.NaN()
+	}
+	return v
+}
+
+// SMA is a simple moving average: the mean of the last period values. It warms
+// up once it has seen period values.
+type SMA struct {
+	period int
+	window []float64
+	next   int
+	sum    float64
+}
+
+// NewSMA creates a simple moving average. It panics if period is less than one.
+func NewSMA(period int) *SMA {
+	checkPeriod(period)
+	return &SMA{period: period, window: make([]float64, 0, period)}
+}
+
+// Update adds the next value, and returns the average.
+func (s *SMA) Update(v float64) (float64, bool) {
+	if len(s.window) < s.period {
+		s.window = append(s.window, v)
+	} else {
+		s.sum -= s.window[s.next]
+		s.window[s.next] = v
+		s.next = (s.next + 1) % s.period
+	}
+	s.sum += v
+	return s.Value()
+}
+
+// Value returns the current average.
+func (s *SMA) Value() (float64, bool) {
+	if len(s.window) < s.period {
+		return 0, false
+	}
+	return s.sum / float64(s.period), true
+}
+
+// SMASeries returns the simple moving average of the closes of candles.
+func SMASeries(candles []qapi.Candlestick, period int) []float64 {
+	s := NewSMA(period)
+	out := make([]float64, len(candles))
+	for k, c := range candles {
+		out[k] = valueOrNaN(s.Update(c.Close.Float64()))
+	}
+	return out
+}
+
+// EMA is an exponential moving average, which weights each value by
+// 2 / (period + 1). It is seeded with the simple average of the first period
+// values, and warms up once it has seen them.
+type EMA struct {
+	period int
+	alpha  float64
+	seed   *SMA
+	value  float64
+	ready  bool
+}
+
+// NewEMA creates an exponential moving average. It panics if period is less than
+// one.
+func NewEMA(period int) *EMA {
+	checkPeriod(period)
+	return &EMA{
+		period: period,
+		alpha:  2 / float64(period+1),
+		seed:   NewSMA(period),
+	}
+}
+
+// Update adds the next value, and returns the average.
+func (e *EMA) Update(v float64) (float64, bool) {
+	if !e.ready {
+		e.value, e.ready = e.seed.Update(v)
+		return e.Value()
+	}
+
+	e.value += e.alpha * (v - e.value)
+	return e.Value()
+}
+
+// Value returns the current average.
+func (e *EMA) Value() (float64, bool) {
+	return e.value, e.ready
+}
+
+// EMASeries returns the exponential moving average of the closes of candles.
+func EMASeries(candles []qapi.Candlestick, period int) []float64 {
+	e := NewEMA(period)
+	out := make([]float64, len(candles))
+	for k, c := range candles {
+		out[k] = valueOrNaN(e.Update(c.Close.Float64()))
+	}
+	return out
+}
@@ -0,0 +1,132 @@
+package indicators_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/indicators"
+)
+
+// Closing prices and 10-day averages from the StockCharts moving average example
+// Ref: https://school.stockcharts.com/doku.php?id=technical_indicators:moving_averages
+var (
+	maCloses = []float64{
+		22.27, 22.19, 22.08, 22.17, 22.18, 22.13, 22.23, 22.43, 22.24, 22.29,
+		22.15, 22.39, 22.38, 22.61, 23.36, 24.05, 23.75, 23.83, 23.95, 23.63,
+		23.82, 23.87, 23.65, 23.19, 23.10, 23.33, 22.68, 23.10, 22.40, 22.17,
+	}
+	maSMA10 = []float64{
+		22.22, 22.21, 22.23, 22.26, 22.31, 22.42, 22.61, 22.77, 22.91, 23.08,
+		23.21, 23.38, 23.53, 23.65, 23.71, 23.69, 23.61, 23.51, 23.43, 23.28, 23.13,
+	}
+	maEMA10 = []float64{
+		22.22, 22.21, 22.24, 22.27, 22.33, 22.52, 22.80, 22.97, 23.13, 23.28,
+		23.34, 23.43, 23.51, 23.54, 23.47, 23.40, 23.39, 23.26, 23.23, 23.08, 22.92,
+	}
+)
+
+// day is the start of the nth daily candle
+func day(n int) time.Time {
+	return time.Date(2020, 1, 1, 14, 30, 0, 0, time.UTC).AddDate(0, 0, n)
+}
+
+// closeCandles returns daily candles with the given closing prices
+func closeCandles(closes []float64) []qapi.Candlestick {
+	candles := make([]qapi.Candlestick, len(closes))
+	for k, c := range closes {
+		price := qapi.MoneyFromFloat(c)
+		candles[k] = qapi.Candlestick{
+			Start: day(k),
+			End:   day(k).Add(6*time.Hour + 30*time.Minute),
+			Open:  price,
+			High:  price,
+			Low:   price,
+			Close: price,
+		}
+	}
+	return candles
+}
+
+// checkSeries checks that a series is NaN before the warm-up index, and matches
+// the expected values within the tolerance from then on.
+func checkSeries(t *testing.T, name string, got []float64, warmup int, want []float64, tolerance float64) {
+	t.Helper()
+	if len(got) != warmup+len(want) {
+		t.Fatalf("%s: got %d values, want %d", name, len(got), warmup+len(want))
+	}
+	for k, v := range got {
+		switch {
+		case k < warmup && !math.IsNaN(v):
+			t.Errorf("%s[%d] = %.4f during warm-up, want NaN", name, k, v)
+		case k >= warmup && !(math.Abs(v-want[k-warmup]) <= tolerance):
+			t.Errorf("%s[%d] = %.4f, want %.2f", name, k, v, want[k-warmup])
+		}
+	}
+}
+
+// checkIncremental checks that the values returned by an indicator's Update match
+// its series, including the warm-up period
+func checkIncremental(t *testing.T, name string, series []float64, update func(k int) (float64, bool)) {
+	t.Helper()
+	for k, want := range series {
+		v, ok := update(k)
+		if ok == math.IsNaN(want) || (ok && v != want) {
+			t.Errorf("%s: Update %d = %v, %v, want %v", name, k, v, ok, want)
+		}
+	}
+}
+
+func TestSMA(t *testing.T) {
+	candles := closeCandles(maCloses)
+	series := indicators.SMASeries(candles, 10)
+	checkSeries(t, "SMA(10)", series, 9, maSMA10, 0.01)
+
+	sma := indicators.NewSMA(10)
+	checkIncremental(t, "SMA(10)", series, func(k int) (float64, bool) {
+		return sma.Update(maCloses[k])
+	})
+	if v, ok := sma.Value(); !ok || v != series[len(series)-1] {
+		t.Errorf("Value = %v, %v, want %v", v, ok, series[len(series)-1])
+	}
+}
+
+func TestEMA(t *testing.T) {
+	candles := closeCandles(maCloses)
+	series := indicators.EMASeries(candles, 10)
+	checkSeries(t, "EMA(10)", series, 9, maEMA10, 0.01)
+
+	ema := indicators.NewEMA(10)
+	checkIncremental(t, "EMA(10)", series, func(k int) (float64, bool) {
+		return ema.Update(maCloses[k])
+	})
+}
+
+func TestPeriodOne(t *testing.T) {
+	candles := closeCandles(maCloses)
+	for name, series := range map[string][]float64{
+		"SMA(1)": indicators.SMASeries(candles, 1),
+		"EMA(1)": indicators.EMASeries(candles, 1),
+	} {
+		checkSeries(t, name, series, 0, maCloses, 1e-9)
+	}
+}
+
+func TestInvalidPeriodPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSMA(0) did not panic")
+		}
+	}()
+	indicators.NewSMA(0)
+}
+
+func TestEmptySeries(t *testing.T) {
+	if got := indicators.SMASeries(nil, 10); len(got) != 0 {
+		t.Errorf("SMASeries(nil) = %v, want no values", got)
+	}
+	if got := indicators.Closes(nil); len(got) != 0 {
+		t.Errorf("Closes(nil) = %v, want no values", got)
+	}
+}
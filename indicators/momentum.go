@@ -0,0 +1,148 @@
+package indicators
+
+import (
+	"math"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// RSI is Wilder's relative strength index, between 0 and 100. The average gain
+// and loss are seeded with the simple average of the first period changes, and
+// then smoothed by 1 / period. It warms up once it has seen period + 1 values.
+type RSI struct {
+	period  int
+	prev    float64
+	count   int
+	avgGain float64
+	avgLoss float64
+}
+
+// NewRSI creates a relative strength index. It panics if period is less than one.
+func NewRSI(period int) *RSI {
+	checkPeriod(period)
+	return &RSI{period: period}
+}
+
+// Update adds the next value, and returns the index.
+func (r *RSI) Update(v float64) (float64, bool) {
+	r.count++
+	if r.count == 1 {
+		r.prev = v
+		return r.Value()
+	}
+
+	change := v - r.prev
+	r.prev = v
+	gain, loss := math.Max(change, 0), math.Max(-change, 0)
+
+	n := float64(r.period)
+	if r.count <= r.period+1 {
+		// Simple average of the first changes
+		r.avgGain += gain / n
+		r.avgLoss += loss / n
+	} else {
+		r.avgGain = (r.avgGain*(n-1) + gain) / n
+		r.avgLoss = (r.avgLoss*(n-1) + loss) / n
+	}
+	return r.Value()
+}
+
+// Value returns the current index. If prices haven't changed over the period, it
+// is 50.
+func (r *RSI) Value() (float64, bool) {
+	if r.count <= r.period {
+		return 0, false
+	}
+
+	switch {
+	case r.avgGain == 0 && r.avgLoss == 0:
+		return 50, true
+	case r.avgLoss == 0:
+		return 100, true
+	}
+	return 100 - 100/(1+r.avgGain/r.avgLoss), true
+}
+
+// RSISeries returns the relative strength index of the closes of candles.
+func RSISeries(candles []qapi.Candlestick, period int) []float64 {
+	r := NewRSI(period)
+	out := make([]float64, len(candles))
+	for k, c := range candles {
+		out[k] = valueOrNaN(r.Update(c.Close.Float64()))
+	}
+	return out
+}
+
+// MACDValue is a value of the moving average convergence divergence indicator.
+type MACDValue struct {
+	// Fast EMA minus slow EMA.
+	MACD float64
+
+	// EMA of the MACD line.
+	Signal float64
+
+	// MACD minus signal.
+	Histogram float64
+}
+
+// MACD is the moving average convergence divergence indicator. The MACD line
+// warms up once it has seen slow values, and the signal line once the MACD line
+// has signal values, which is after slow + signal - 1 values.
+type MACD struct {
+	fast   *EMA
+	slow   *EMA
+	signal *EMA
+	value  MACDValue
+	line   bool
+	ready  bool
+}
+
+// NewMACD creates a moving average convergence divergence indicator with EMAs of
+// the given periods, typically 12, 26 and 9. It panics if a period is less than one.
+func NewMACD(fast int, slow int, signal int) *MACD {
+	return &MACD{
+		fast:   NewEMA(fast),
+		slow:   NewEMA(slow),
+		signal: NewEMA(signal),
+	}
+}
+
+// Update adds the next value, and returns the indicator. It is only reported as
+// warmed up once the signal line has warmed up.
+func (m *MACD) Update(v float64) (MACDValue, bool) {
+	f, fok := m.fast.Update(v)
+	s, sok := m.slow.Update(v)
+	if !fok || !sok {
+		return m.Value()
+	}
+
+	m.line = true
+	m.value.MACD = f - s
+	if sig, ok := m.signal.Update(m.value.MACD); ok {
+		m.ready = true
+		m.value.Signal = sig
+		m.value.Histogram = m.value.MACD - sig
+	}
+	return m.Value()
+}
+
+// Value returns the current indicator.
+func (m *MACD) Value() (MACDValue, bool) {
+	return m.value, m.ready
+}
+
+// MACDSeries returns the moving average convergence divergence of the closes of
+// candles. Each field is NaN until its line has warmed up.
+func MACDSeries(candles []qapi.Candlestick, fast int, slow int, signal int) []MACDValue {
+	m := NewMACD(fast, slow, signal)
+	out := make([]MACDValue, len(candles))
+	for k, c := range candles {
+		v, ok := m.Update(c.Close.Float64())
+		out[k] = MACDValue{
+			MACD:      valueOrNaN(v.MACD, m.line),
+			Signal:    valueOrNaN(v.Signal, ok),
+			Histogram: valueOrNaN(v.Histogram, ok),
+		}
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package indicators_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alexurquhart/qapi/indicators"
+)
+
+// Closing prices and RSI(14) from the StockCharts RSI example. The spreadsheet
+// rounds its intermediate values, so its RSI differs from the exact calculation
+// (which TA-Lib agrees with) by up to 0.07.
+// Ref: https://school.stockcharts.com/doku.php?id=technical_indicators:relative_strength_index_rsi
+var (
+	rsiCloses = []float64{
+		44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08,
+		45.89, 46.03, 45.61, 46.28, 46.28, 46.00, 46.03, 46.41, 46.22, 45.64,
+		46.21, 46.25, 45.71, 46.45, 45.78, 45.35, 44.03, 44.18, 44.22, 44.57,
+		43.42, 42.66, 43.13,
+	}
+	rsi14 = []float64{
+		70.53, 66.32, 66.55, 69.41, 66.36, 57.97, 62.93, 63.26, 56.06, 62.38,
+		54.71, 50.42, 39.99, 41.46, 41.87, 45.46, 37.30, 33.08, 37.77,
+	}
+)
+
+func TestRSI(t *testing.T) {
+	series := indicators.RSISeries(closeCandles(rsiCloses), 14)
+	checkSeries(t, "RSI(14)", series, 14, rsi14, 0.1)
+
+	rsi := indicators.NewRSI(14)
+	checkIncremental(t, "RSI(14)", series, func(k int) (float64, bool) {
+		return rsi.Update(rsiCloses[k])
+	})
+}
+
+func TestRSIWithoutChanges(t *testing.T) {
+	rsi := indicators.NewRSI(3)
+	for k := 0; k < 4; k++ {
+		rsi.Update(10)
+	}
+	if v, ok := rsi.Value(); !ok || v != 50 {
+		t.Errorf("RSI of constant prices = %v, %v, want 50", v, ok)
+	}
+
+	rsi = indicators.NewRSI(3)
+	for k := 0; k < 4; k++ {
+		rsi.Update(float64(10 + k))
+	}
+	if v, ok := rsi.Value(); !ok || v != 100 {
+		t.Errorf("RSI of rising prices = %v, %v, want 100", v, ok)
+	}
+}
+
+// ema calculates an exponential moving average the way it is defined, seeded with
+// the simple average of the first period values. Values before that are NaN.
+func ema(values []float64, period int) []float64 {
+	out := make([]float64, len(values))
+	alpha := 2 / float64(period+1)
+	for k := range values {
+		switch {
+		case k < period-1:
+			out[k] = math.NaN()
+		case k == period-1:
+			sum := 0.0
+			for _, v := range values[:period] {
+				sum += v
+			}
+			out[k] = sum / float64(period)
+		default:
+			out[k] = alpha*values[k] + (1-alpha)*out[k-1]
+		}
+	}
+	return out
+}
+
+func TestMACD(t *testing.T) {
+	const fast, slow, signal = 3, 6, 4
+
+	// The MACD line starts once the slow EMA has warmed up, and the signal line
+	// is an EMA of the MACD line from then on
+	fastEMA, slowEMA := ema(maCloses, fast), ema(maCloses, slow)
+	line := make([]float64, len(maCloses)-(slow-1))
+	for k := range line {
+		line[k] = fastEMA[k+slow-1] - slowEMA[k+slow-1]
+	}
+	sig := ema(line, signal)[signal-1:]
+	hist := make([]float64, len(sig))
+	for k := range sig {
+		hist[k] = line[k+signal-1] - sig[k]
+	}
+
+	series := indicators.MACDSeries(closeCandles(maCloses), fast, slow, signal)
+	fields := func(f func(v indicators.MACDValue) float64) []float64 {
+		out := make([]float64, len(series))
+		for k, v := range series {
+			out[k] = f(v)
+		}
+		return out
+	}
+	macdLine := fields(func(v indicators.MACDValue) float64 { return v.MACD })
+	signalLine := fields(func(v indicators.MACDValue) float64 { return v.Signal })
+	histogram := fields(func(v indicators.MACDValue) float64 { return v.Histogram })
+
+	checkSeries(t, "MACD", macdLine, slow-1, line, 1e-9)
+	checkSeries(t, "Signal", signalLine, slow+signal-2, sig, 1e-9)
+	checkSeries(t, "Histogram", histogram, slow+signal-2, hist, 1e-9)
+
+	// Update only reports the indicator as ready once the signal line is
+	macd := indicators.NewMACD(fast, slow, signal)
+	checkIncremental(t, "MACD", signalLine, func(k int) (float64, bool) {
+		v, ok := macd.Update(maCloses[k])
+		return v.Signal, ok
+	})
+	if v, _ := macd.Value(); v != series[len(series)-1] {
+		t.Errorf("Value = %+v, want %+v", v, series[len(series)-1])
+	}
+}
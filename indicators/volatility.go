@@ -0,0 +1,175 @@
+package indicators
+
+import (
+	"math"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// BollingerValue is a value of Bollinger Bands.
+type BollingerValue struct {
+	// Simple moving average.
+	Middle float64
+
+	// Average plus and minus the given number of standard deviations.
+	Upper float64
+	Lower float64
+}
+
+// Bollinger is Bollinger Bands: a simple moving average, with bands a number of
+// population standard deviations above and below it. It warms up once it has
+// seen period values.
+type Bollinger struct {
+	sma   *SMA
+	k     float64
+	value BollingerValue
+	ready bool
+}
+
+// NewBollinger creates Bollinger Bands over the given period, typically 20, with
+// bands k standard deviations from the average, typically 2. It panics if period
+// is less than one.
+func NewBollinger(period int, k float64) *Bollinger {
+	return &Bollinger{sma: NewSMA(period), k: k}
+}
+
+// Update adds the next value, and returns the bands.
+func (b *Bollinger) Update(v float64) (BollingerValue, bool) {
+	mean, ok := b.sma.Update(v)
+	if !ok {
+		return b.Value()
+	}
+
+	var variance float64
+	for _, w := range b.sma.window {
+		variance += (w - mean) * (w - mean)
+	}
+	sd := math.Sqrt(variance / float64(len(b.sma.window)))
+
+	b.ready = true
+	b.value = BollingerValue{mean, mean + b.k*sd, mean - b.k*sd}
+	return b.Value()
+}
+
+// Value returns the current bands.
+func (b *Bollinger) Value() (BollingerValue, bool) {
+	return b.value, b.ready
+}
+
+// BollingerSeries returns the Bollinger Bands of the closes of candles. Every
+// field is NaN during the warm-up period.
+func BollingerSeries(candles []qapi.Candlestick, period int, k float64) []BollingerValue {
+	b := NewBollinger(period, k)
+	out := make([]BollingerValue, len(candles))
+	for i, c := range candles {
+		v, ok := b.Update(c.Close.Float64())
+		if !ok {
+			v = BollingerValue{math.NaN(), math.NaN(), math.NaN()}
+		}
+		out[i] = v
+	}
+	return out
+}
+
+// ATR is Wilder's average true range. The true range of the first candle is its
+// range, since there is no previous close. The average is seeded with the simple
+// average of the first period true ranges, and then smoothed by 1 / period. It
+// warms up once it has seen period candles.
+type ATR struct {
+	period    int
+	count     int
+	prevClose float64
+	value     float64
+}
+
+// NewATR creates an average true range. It panics if period is less than one.
+func NewATR(period int) *ATR {
+	checkPeriod(period)
+	return &ATR{period: period}
+}
+
+// Update adds the next candle, and returns the average true range.
+func (a *ATR) Update(c qapi.Candlestick) (float64, bool) {
+	high, low, close := c.High.Float64(), c.Low.Float64(), c.Close.Float64()
+
+	tr := high - low
+	if a.count > 0 {
+		tr = math.Max(tr, math.Max(math.Abs(high-a.prevClose), math.Abs(low-a.prevClose)))
+	}
+	a.prevClose = close
+	a.count++
+
+	n := float64(a.period)
+	if a.count <= a.period {
+		a.value += tr / n
+	} else {
+		a.value = (a.value*(n-1) + tr) / n
+	}
+	return a.Value()
+}
+
+// Value returns the current average true range.
+func (a *ATR) Value() (float64, bool) {
+	if a.count < a.period {
+		return 0, false
+	}
+	return a.value, true
+}
+
+// ATRSeries returns the average true range of candles.
+func ATRSeries(candles []qapi.Candlestick, period int) []float64 {
+	a := NewATR(period)
+	out := make([]float64, len(candles))
+	for k, c := range candles {
+		out[k] = valueOrNaN(a.Update(c))
+	}
+	return out
+}
+
+// VWAP is the volume weighted average price of the candles since the start of the
+// day, using the typical price (high + low + close) / 3 of each candle. It starts
+// over with the first candle of each day, in the time zone of the candle's start
+// time. It warms up once it has seen a candle with volume.
+type VWAP struct {
+	day    time.Time
+	pv     float64
+	volume float64
+}
+
+// NewVWAP creates a volume weighted average price.
+func NewVWAP() *VWAP {
+	return &VWAP{}
+}
+
+// Update adds the next candle, and returns the volume weighted average price.
+func (v *VWAP) Update(c qapi.Candlestick) (float64, bool) {
+	y, m, d := c.Start.Date()
+	day := time.Date(y, m, d, 0, 0, 0, 0, c.Start.Location())
+	if !day.Equal(v.day) {
+		v.day, v.pv, v.volume = day, 0, 0
+	}
+
+	typical := (c.High.Float64() + c.Low.Float64() + c.Close.Float64()) / 3
+	v.pv += typical * float64(c.Volume)
+	v.volume += float64(c.Volume)
+	return v.Value()
+}
+
+// Value returns the current volume weighted average price.
+func (v *VWAP) Value() (float64, bool) {
+	if v.volume == 0 {
+		return 0, false
+	}
+	return v.pv / v.volume, true
+}
+
+// VWAPSeries returns the volume weighted average price of candles.
+func VWAPSeries(candles []qapi.Candlestick) []float64 {
+	v := NewVWAP()
+	out := make([]float64, len(candles))
+	for k, c := range candles {
+		out[k] = valueOrNaN(v.Update(c))
+	}
+	return out
+}
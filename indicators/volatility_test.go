@@ -0,0 +1,130 @@
+package indicators_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/indicators"
+)
+
+// Prices and ATR(14) from the StockCharts average true range example. The
+// spreadsheet rounds every step to the cent, so it differs from the exact
+// calculation by up to 0.01.
+// Ref: https://school.stockcharts.com/doku.php?id=technical_indicators:average_true_range_atr
+var (
+	atrHighs = []float64{
+		48.70, 48.72, 48.90, 48.87, 48.82, 49.05, 49.20, 49.35, 49.92, 50.19,
+		50.12, 49.66, 49.88, 50.19, 50.36, 50.57, 50.65, 50.43, 49.63, 50.33,
+		50.29, 50.17, 49.32, 48.50, 48.32, 46.80, 47.80, 48.39, 48.66, 48.79,
+	}
+	atrLows = []float64{
+		47.79, 48.14, 48.39, 48.37, 48.24, 48.64, 48.94, 48.86, 49.50, 49.87,
+		49.20, 48.90, 49.43, 49.73, 49.26, 50.09, 50.30, 49.21, 48.98, 49.61,
+		49.20, 49.43, 48.08, 47.64, 41.55, 44.28, 47.31, 47.20, 47.90, 47.73,
+	}
+	atrCloses = []float64{
+		48.16, 48.61, 48.75, 48.63, 48.74, 49.03, 49.07, 49.32, 49.91, 50.13,
+		49.53, 49.50, 49.75, 50.03, 50.31, 50.52, 50.41, 49.34, 49.37, 50.23,
+		49.24, 49.93, 48.43, 48.18, 46.57, 45.41, 47.77, 47.72, 48.62, 47.85,
+	}
+	atr14 = []float64{
+		0.55, 0.59, 0.58, 0.56, 0.61, 0.61, 0.64, 0.67, 0.69, 0.77,
+		0.78, 1.21, 1.30, 1.38, 1.37, 1.34, 1.32,
+	}
+)
+
+func TestATR(t *testing.T) {
+	candles := closeCandles(atrCloses)
+	for k := range candles {
+		candles[k].High = qapi.MoneyFromFloat(atrHighs[k])
+		candles[k].Low = qapi.MoneyFromFloat(atrLows[k])
+	}
+
+	series := indicators.ATRSeries(candles, 14)
+	checkSeries(t, "ATR(14)", series, 13, atr14, 0.01)
+
+	atr := indicators.NewATR(14)
+	checkIncremental(t, "ATR(14)", series, func(k int) (float64, bool) {
+		return atr.Update(candles[k])
+	})
+}
+
+func TestBollinger(t *testing.T) {
+	const period, k = 20, 2
+
+	// Population standard deviation of each window of closes
+	var middle, upper, lower []float64
+	for end := period; end <= len(maCloses); end++ {
+		window := maCloses[end-period : end]
+		mean := 0.0
+		for _, v := range window {
+			mean += v / period
+		}
+		variance := 0.0
+		for _, v := range window {
+			variance += (v - mean) * (v - mean) / period
+		}
+		sd := math.Sqrt(variance)
+
+		middle = append(middle, mean)
+		upper = append(upper, mean+k*sd)
+		lower = append(lower, mean-k*sd)
+	}
+
+	series := indicators.BollingerSeries(closeCandles(maCloses), period, k)
+	fields := func(f func(v indicators.BollingerValue) float64) []float64 {
+		out := make([]float64, len(series))
+		for i, v := range series {
+			out[i] = f(v)
+		}
+		return out
+	}
+	checkSeries(t, "Middle", fields(func(v indicators.BollingerValue) float64 { return v.Middle }), period-1, middle, 1e-9)
+	checkSeries(t, "Upper", fields(func(v indicators.BollingerValue) float64 { return v.Upper }), period-1, upper, 1e-9)
+	checkSeries(t, "Lower", fields(func(v indicators.BollingerValue) float64 { return v.Lower }), period-1, lower, 1e-9)
+
+	b := indicators.NewBollinger(period, k)
+	checkIncremental(t, "Bollinger", fields(func(v indicators.BollingerValue) float64 { return v.Upper }), func(i int) (float64, bool) {
+		v, ok := b.Update(maCloses[i])
+		return v.Upper, ok
+	})
+}
+
+func TestVWAP(t *testing.T) {
+	candle := func(start time.Time, high, low, close string, volume int) qapi.Candlestick {
+		return qapi.Candlestick{
+			Start:  start,
+			End:    start.Add(time.Minute),
+			High:   qapi.MustParseMoney(high),
+			Low:    qapi.MustParseMoney(low),
+			Close:  qapi.MustParseMoney(close),
+			Volume: volume,
+		}
+	}
+
+	candles := []qapi.Candlestick{
+		// Typical prices 9 and 11, weighted 1:3
+		candle(day(0), "10", "8", "9", 100),
+		candle(day(0).Add(time.Minute), "12", "10", "11", 300),
+
+		// The next day starts over, and isn't ready until a candle has volume
+		candle(day(1), "20", "20", "20", 0),
+		candle(day(1).Add(time.Minute), "21", "19", "20", 50),
+		candle(day(1).Add(2*time.Minute), "24", "22", "23", 150),
+	}
+
+	series := indicators.VWAPSeries(candles)
+	want := []float64{9, 10.5, math.NaN(), 20, 22.25}
+	for k, w := range want {
+		if got := series[k]; !(got == w || math.IsNaN(got) && math.IsNaN(w)) {
+			t.Errorf("VWAP[%d] = %v, want %v", k, got, w)
+		}
+	}
+
+	vwap := indicators.NewVWAP()
+	checkIncremental(t, "VWAP", series, func(k int) (float64, bool) {
+		return vwap.Update(candles[k])
+	})
+}
@@ -15,13 +15,13 @@ type Symbol struct {
 	SymbolID int `json:"symbolId"`
 
 	// Closing trade price from the previous trading day.
-	PrevDayClosePrice float32 `json:"prevDayClosePrice"`
+	PrevDayClosePrice Money `json:"prevDayClosePrice"`
 
 	// 52-week high price.
-	HighPrice52 float32 `json:"highPrice52"`
+	HighPrice52 Money `json:"highPrice52"`
 
 	// 52-week low price.
-	LowPrice52 float32 `json:"lowPrice52"`
+	LowPrice52 Money `json:"lowPrice52"`
 
 	// Average trading volume over trailing 3 months.
 	AverageVol3Months int `json:"averageVol3Months"`
@@ -33,13 +33,13 @@ type Symbol struct {
 	OutstandingShares int `json:"outstandingShares"`
 
 	// Trailing 12-month earnings per share.
-	EPS float32 `json:"eps"`
+	EPS Money `json:"eps"`
 
 	// Trailing 12-month price to earnings ratio.
 	PE float32 `json:"pe"`
 
 	// Dividend amount per share.
-	Dividend float32 `json:"dividend"`
+	Dividend Money `json:"dividend"`
 
 	// Dividend yield (dividend / prevDayClosePrice).
 	Yield float32 `json:"yield"`
@@ -48,13 +48,13 @@ type Symbol struct {
 	ExDate *time.Time `json:"exDate"`
 
 	// Market capitalization (outstandingShares * prevDayClosePrice).
-	MarketCap float32 `json:"marketCap"`
+	MarketCap Money `json:"marketCap"`
 
 	// Option type (e.g., "Call").
-	OptionType string `json:"optionType"`
+	OptionType OptionType `json:"optionType"`
 
 	// Option duration type (e.g., "Weekly").
-	OptionDurationType string `json:"optionDurationType"`
+	OptionDurationType OptionDurationType `json:"optionDurationType"`
 
 	// Option root symbol (e.g., "MSFT").
 	OptionRoot string `json:"optionRoot"`
@@ -63,16 +63,16 @@ type Symbol struct {
 	OptionContractDeliverables OptionContractDeliverables `json:"optionContractDeliverables"`
 
 	// Option exercise style (e.g., "American").
-	OptionExerciseType string `json:"optionExerciseType"`
+	OptionExerciseType OptionExerciseType `json:"optionExerciseType"`
 
 	// Primary listing exchange.
-	ListingExchange string `json:"listingExchange"`
+	ListingExchange ListingExchange `json:"listingExchange"`
 
 	// Symbol description (e.g., "Microsoft Corp.").
 	Description string `json:"description"`
 
 	// Security type (e.g., "Stock").
-	SecurityType string `json:"securityType"`
+	SecurityType SecurityType `json:"securityType"`
 
 	// Option expiry date.
 	OptionExpiryDate *time.Time `json:"optionExpiryDate"`
@@ -81,7 +81,7 @@ type Symbol struct {
 	DividendDate *time.Time `json:"dividendDate"`
 
 	// Option strike price.
-	OptionStrikePrice float32 `json:"optionStrikePrice"`
+	OptionStrikePrice Money `json:"optionStrikePrice"`
 
 	// Indicates whether the symbol is actively listed.
 	IsQuotable bool `json:"isQuotable"`
@@ -90,7 +90,7 @@ type Symbol struct {
 	HasOptions bool `json:"hasOptions"`
 
 	// String Currency code (follows ISO format).
-	Currency string `json:"currency"`
+	Currency Currency `json:"currency"`
 
 	// List of MinTickData records.
 	MinTicks []MinTickData `json:"minTicks"`
@@ -104,30 +104,30 @@ type UnderlyingMultiplierPair struct {
 
 type OptionContractDeliverables struct {
 	Underlyings []UnderlyingMultiplierPair `json:"underlyings"`
-	CashInLieu  float32                    `json:"cashInLieu"`
+	CashInLieu  Money                      `json:"cashInLieu"`
 }
 
 type MinTickData struct {
-	Pivot   float32 `json:"pivot"`
-	MinTick float32 `json:"minTick"`
+	Pivot   Money `json:"pivot"`
+	MinTick Money `json:"minTick"`
 }
 
 // Symbol information retreived from search results
 // Ref: http://www.questrade.com/api/documentation/rest-operations/market-calls/symbols-search
 type SymbolSearchResult struct {
-	Symbol          string `json:"symbol"`
-	SymbolID        int    `json:"symbolId"`
-	Description     string `json:"description"`
-	SecurityType    string `json:"securityType"`
-	ListingExchange string `json:"listingExchange"`
-	IsQuotable      bool   `json:"isQuotable"`
-	IsTradable      bool   `json:"isTradable"`
-	Currency        string `json:"currency"`
+	Symbol          string          `json:"symbol"`
+	SymbolID        int             `json:"symbolId"`
+	Description     string          `json:"description"`
+	SecurityType    SecurityType    `json:"securityType"`
+	ListingExchange ListingExchange `json:"listingExchange"`
+	IsQuotable      bool            `json:"isQuotable"`
+	IsTradable      bool            `json:"isTradable"`
+	Currency        Currency        `json:"currency"`
 }
 
 type ChainPerStrikePrice struct {
 	// Option strike price.
-	StrikePrice float32 `json:"strikePrice"`
+	StrikePrice Money `json:"strikePrice"`
 
 	// Internal identifier of the call option symbol.
 	CallSymbolID int `json:"callSymbolId"`
@@ -157,10 +157,10 @@ type OptionChain struct {
 	Description string `json:"description"`
 
 	// Primary listing exchange.
-	ListingExchange string `json:"listingExchange"`
+	ListingExchange ListingExchange `json:"listingExchange"`
 
 	// Option exercise style (e.g., "American").
-	OptionExerciseType string `json:"optionExerciseType"`
+	OptionExerciseType OptionExerciseType `json:"optionExerciseType"`
 
 	// Slice of ChainPerRoot elements
 	ChainPerRoot []ChainPerRoot `json:"chainPerRoot"`
@@ -178,10 +178,10 @@ type Market struct {
 	DefaultTradingVenue string `json:"defaultTradingVenue"`
 
 	// List of primary order route codes.
-	PrimaryOrderRoutes []string `json:"primaryOrderRoutes"`
+	PrimaryOrderRoutes []OrderRoute `json:"primaryOrderRoutes"`
 
 	// List of secondary order route codes.
-	SecondaryOrderRoutes []string `json:"secondaryOrderRoutes"`
+	SecondaryOrderRoutes []OrderRoute `json:"secondaryOrderRoutes"`
 
 	// List of level 1 market data feed codes.
 	Level1Feeds []string `json:"level1Feeds"`
@@ -202,7 +202,7 @@ type Market struct {
 	ExtendedEndTime time.Time `json:"extendedEndTime"`
 
 	// Currency code (ISO format).
-	Currency string `json:"currency"`
+	Currency Currency `json:"currency"`
 
 	// Number of snap quotes that the user can retrieve from a market.
 	SnapQuotesLimit int `json:"snapQuotesLimit"`
@@ -220,28 +220,28 @@ type Quote struct {
 	Tier string `json:"tier"`
 
 	// Bid price.
-	BidPrice float32 `json:"bidPrice"`
+	BidPrice Money `json:"bidPrice"`
 
 	// Bid quantity.
 	BidSize int `json:"bidSize"`
 
 	// Ask price.
-	AskPrice float32 `json:"askPrice"`
+	AskPrice Money `json:"askPrice"`
 
 	// Ask quantity.
 	AskSize int `json:"askSize"`
 
 	// Price of the last trade during regular trade hours.
-	LastTradeTrHrs float32 `json:"lastTradeTrHrs"`
+	LastTradeTrHrs Money `json:"lastTradeTrHrs"`
 
 	// Price of the last trade.
-	LastTradePrice float32 `json:"lastTradePrice"`
+	LastTradePrice Money `json:"lastTradePrice"`
 
 	// Quantity of the last trade.
 	LastTradeSize int `json:"lastTradeSize"`
 
 	// Trade direction.
-	LastTradeTick string `json:"lastTradeTick"`
+	LastTradeTick TickType `json:"lastTradeTick"`
 
 	// Timestamp
 	LastTtradeTime string `json:"lastTradeTime"`
@@ -250,13 +250,13 @@ type Quote struct {
 	Volume int `json:"volume"`
 
 	// Opening trade price.
-	OpenPrice float32 `json:"openPrice"`
+	OpenPrice Money `json:"openPrice"`
 
 	// Daily high price.
-	HighPrice float32 `json:"highPrice"`
+	HighPrice Money `json:"highPrice"`
 
 	// Daily low price.
-	LowPrice float32 `json:"lowPrice"`
+	LowPrice Money `json:"lowPrice"`
 
 	// Whether a quote is delayed (true) or real-time.
 	Delay int `json:"delay"`
@@ -265,6 +265,112 @@ type Quote struct {
 	IsHalted bool `json:"isHalted"`
 }
 
+// OptionQuote represents a Lvl 1 market data quote for an option, along with its
+// implied volatility and greeks.
+//
+// Ref: http://www.questrade.com/api/documentation/rest-operations/market-calls/markets-quotes-options
+type OptionQuote struct {
+	// Underlying name.
+	Underlying string `json:"underlying"`
+
+	// Underlying symbol identifier.
+	UnderlyingID int `json:"underlyingId"`
+
+	// Symbol name following Questrade’s symbology.
+	Symbol string `json:"symbol"`
+
+	// Internal symbol identifier.
+	SymbolID int `json:"symbolId"`
+
+	// Bid price.
+	BidPrice Money `json:"bidPrice"`
+
+	// Bid quantity.
+	BidSize int `json:"bidSize"`
+
+	// Ask price.
+	AskPrice Money `json:"askPrice"`
+
+	// Ask quantity.
+	AskSize int `json:"askSize"`
+
+	// Price of the last trade during regular trade hours.
+	LastTradePriceTrHrs Money `json:"lastTradePriceTrHrs"`
+
+	// Price of the last trade.
+	LastTradePrice Money `json:"lastTradePrice"`
+
+	// Quantity of the last trade.
+	LastTradeSize int `json:"lastTradeSize"`
+
+	// Trade direction.
+	LastTradeTick TickType `json:"lastTradeTick"`
+
+	// Timestamp
+	LastTradeTime string `json:"lastTradeTime"`
+
+	// Volume.
+	Volume int `json:"volume"`
+
+	// Opening trade price.
+	OpenPrice Money `json:"openPrice"`
+
+	// Daily high price.
+	HighPrice Money `json:"highPrice"`
+
+	// Daily low price.
+	LowPrice Money `json:"lowPrice"`
+
+	// Implied volatility.
+	Volatility float32 `json:"volatility"`
+
+	// Delta.
+	Delta float32 `json:"delta"`
+
+	// Gamma.
+	Gamma float32 `json:"gamma"`
+
+	// Theta.
+	Theta float32 `json:"theta"`
+
+	// Vega.
+	Vega float32 `json:"vega"`
+
+	// Rho.
+	Rho float32 `json:"rho"`
+
+	// Number of contracts open.
+	OpenInterest int `json:"openInterest"`
+
+	// Whether a quote is delayed (true) or real-time.
+	Delay int `json:"delay"`
+
+	// Whether trading in the symbol is currently halted.
+	IsHalted bool `json:"isHalted"`
+
+	// Volume weighted average price.
+	VWAP Money `json:"VWAP"`
+}
+
+// OptionQuoteFilter selects options to quote by their underlying, expiry date and
+// strike price.
+type OptionQuoteFilter struct {
+	// Option type (e.g., "Call"). Both calls and puts are quoted if empty.
+	OptionType OptionType `json:"optionType,omitempty"`
+
+	// Internal symbol identifier of the underlying.
+	UnderlyingID int `json:"underlyingId"`
+
+	// Option expiry date.
+	ExpiryDate time.Time `json:"expiryDate"`
+
+	// Lowest strike price to quote.
+	MinStrikePrice Money `json:"minstrikePrice,omitzero"`
+
+	// Highest strike price to quote.
+	MaxStrikePrice Money `json:"maxstrikePrice,omitzero"`
+}
+
 // Candlestick represents historical market data in the form of OHLC candlesticks
 // for a specified symbol.
 type Candlestick struct {
@@ -275,17 +381,143 @@ type Candlestick struct {
 	End time.Time `json:"end"`
 
 	// Opening price.
-	Open float32 `json:"open"`
+	Open Money `json:"open"`
 
 	// High price.
-	High float32 `json:"high"`
+	High Money `json:"high"`
 
 	// Low price.
-	Low float32 `json:"low"`
+	Low Money `json:"low"`
 
 	// Closing price.
-	Close float32 `json:"close"`
+	Close Money `json:"close"`
 
 	// Trading volume.
 	Volume int `json:"volume"`
 }
+
+// StrategyVariantLeg is a leg of a strategy variant.
+type StrategyVariantLeg struct {
+	// Internal symbol identifier of the leg.
+	SymbolID int `json:"symbolId"`
+
+	// Leg quantity, relative to the quantity of the other legs.
+	Ratio int `json:"ratio"`
+
+	// Leg side (e.g., "Buy").
+	Action OrderSide `json:"action"`
+}
+
+// StrategyVariant is a combination of legs to be quoted as a single strategy.
+//
+// Ref: http://www.questrade.com/api/documentation/rest-operations/market-calls/markets-quotes-strategies
+type StrategyVariant struct {
+	// Identifier of the variant, used to match it with its quote.
+	VariantID int `json:"variantId"`
+
+	// Type of the strategy (e.g., "Custom").
+	Strategy StrategyType `json:"strategy"`
+
+	// Legs of the strategy.
+	Legs []StrategyVariantLeg `json:"legs"`
+}
+
+// StrategyQuote is a quote for a strategy variant as a whole.
+//
+// Ref: http://www.questrade.com/api/documentation/rest-operations/market-calls/markets-quotes-strategies
+type StrategyQuote struct {
+	// Identifier of the variant that was quoted.
+	VariantID int `json:"variantId"`
+
+	// Bid price.
+	BidPrice Money `json:"bidPrice"`
+
+	// Ask price.
+	AskPrice Money `json:"askPrice"`
+
+	// Underlying name.
+	Underlying string `json:"underlying"`
+
+	// Underlying symbol identifier.
+	UnderlyingID int `json:"underlyingId"`
+
+	// Opening price.
+	OpenPrice Money `json:"openPrice"`
+
+	// Implied volatility.
+	Volatility float32 `json:"volatility"`
+
+	// Delta.
+	Delta float32 `json:"delta"`
+
+	// Gamma.
+	Gamma float32 `json:"gamma"`
+
+	// Theta.
+	Theta float32 `json:"theta"`
+
+	// Vega.
+	Vega float32 `json:"vega"`
+
+	// Rho.
+	Rho float32 `json:"rho"`
+
+	// Whether the quote is real-time (true) or delayed.
+	IsRealTime bool `json:"isRealTime"`
+}
+
+// Strike returns the chain entry with the given strike price, searching every
+// option root of the chain.
+func (o OptionChain) Strike(price Money) (ChainPerStrikePrice, bool) {
+	for _, root := range o.ChainPerRoot {
+		for _, s := range root.ChainPerStrikePrice {
+			if s.StrikePrice == price {
+				return s, true
+			}
+		}
+	}
+	return ChainPerStrikePrice{}, false
+}
+
+// CallLeg returns a strategy leg on the call option at this strike.
+func (s ChainPerStrikePrice) CallLeg(action OrderSide, ratio int) StrategyVariantLeg {
+	return StrategyVariantLeg{SymbolID: s.CallSymbolID, Ratio: ratio, Action: action}
+}
+
+// PutLeg returns a strategy leg on the put option at this strike.
+func (s ChainPerStrikePrice) PutLeg(action OrderSide, ratio int) StrategyVariantLeg {
+	return StrategyVariantLeg{SymbolID: s.PutSymbolID, Ratio: ratio, Action: action}
+}
+
+// NewVerticalSpread builds a variant that buys the option at one strike, and sells
+// the option at another strike of the same expiry. Calls are used if call is true,
+// puts otherwise.
+func NewVerticalSpread(variantID int, long ChainPerStrikePrice, short ChainPerStrikePrice, call bool) StrategyVariant {
+	v := StrategyVariant{VariantID: variantID, Strategy: StrategyVerticalPutSpread}
+	if call {
+		v.Strategy = StrategyVerticalCallSpread
+		v.Legs = []StrategyVariantLeg{long.CallLeg(SideBuy, 1), short.CallLeg(SideSell, 1)}
+	} else {
+		v.Legs = []StrategyVariantLeg{long.PutLeg(SideBuy, 1), short.PutLeg(SideSell, 1)}
+	}
+	return v
+}
+
+// NewStraddle builds a variant that buys or sells both the call and the put at a strike.
+func NewStraddle(variantID int, strike ChainPerStrikePrice, action OrderSide) StrategyVariant {
+	return StrategyVariant{
+		VariantID: variantID,
+		Strategy:  StrategyStraddle,
+		Legs:      []StrategyVariantLeg{strike.CallLeg(action, 1), strike.PutLeg(action, 1)},
+	}
+}
+
+// NewStrangle builds a variant that buys or sells the put at one strike, and the
+// call at another.
+func NewStrangle(variantID int, put ChainPerStrikePrice, call ChainPerStrikePrice, action OrderSide) StrategyVariant {
+	return StrategyVariant{
+		VariantID: variantID,
+		Strategy:  StrategyStrangle,
+		Legs:      []StrategyVariantLeg{put.PutLeg(action, 1), call.CallLeg(action, 1)},
+	}
+}
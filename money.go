@@ -0,0 +1,289 @@
+package qapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// moneyScale is the number of fractional units in one whole unit of Money
+const moneyScale = 1000000
+
+// moneyDecimals is the number of decimal places Money is stored with
+const moneyDecimals = 6
+
+// Money is an exact decimal amount, such as a price, balance or profit/loss. It
+// is stored as a fixed-point number with six decimal places, which is enough to
+// represent sub-penny prices and balances of up to several trillion dollars
+// exactly. The zero value is zero.
+//
+// Money is decoded from and encoded to JSON numbers without passing through a
+// floating point value, so no precision is lost.
+type Money struct {
+	micros int64
+}
+
+// MoneyFromMicros returns the amount with the given number of millionths of a unit.
+func MoneyFromMicros(micros int64) Money {
+	return Money{micros}
+}
+
+// MoneyFromInt returns the amount with the given number of whole units.
+func MoneyFromInt(units int64) Money {
+	return Money{units * moneyScale}
+}
+
+// MoneyFromFloat returns the amount closest to a floating point number, rounded
+// to six decimal places.
+func MoneyFromFloat(f float64) Money {
+	return Money{int64(math.Round(f * moneyScale))}
+}
+
+// ParseMoney parses a decimal number (e.g., "10.25", "-0.0001" or "1.5E-05").
+// Digits beyond six decimal places are rounded half away from zero.
+func ParseMoney(s string) (Money, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return Money{}, fmt.Errorf("Error: Invalid money amount %q", s)
+	}
+
+	r.Mul(r, big.NewRat(moneyScale, 1))
+	m, ok := roundRat(r)
+	if !ok {
+		return Money{}, fmt.Errorf("Error: Money amount %q out of range", s)
+	}
+	return m, nil
+}
+
+// MustParseMoney is like ParseMoney, but panics if the amount can't be parsed. It
+// is intended for constants, e.g. qapi.MustParseMoney("10.25").
+func MustParseMoney(s string) Money {
+	m, err := ParseMoney(s)
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+// roundRat rounds a number of micros to the nearest integer, half away from zero.
+// Returns false if the result doesn't fit.
+func roundRat(r *big.Rat) (Money, bool) {
+	// (2|num| + den) / 2den, truncated, is |num/den| rounded half up
+	num := new(big.Int).Abs(r.Num())
+	num.Mul(num, big.NewInt(2))
+	num.Add(num, r.Denom())
+	num.Quo(num, new(big.Int).Mul(r.Denom(), big.NewInt(2)))
+
+	if r.Sign() < 0 {
+		num.Neg(num)
+	}
+
+	if !num.IsInt64() {
+		return Money{}, false
+	}
+	return Money{num.Int64()}, true
+}
+
+// Micros returns the amount in millionths of a unit.
+func (m Money) Micros() int64 {
+	return m.micros
+}
+
+// Float64 returns the floating point number closest to the amount. Use it for
+// statistics and display, not for further arithmetic on money.
+func (m Money) Float64() float64 {
+	return float64(m.micros) / moneyScale
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.micros == 0
+}
+
+// Sign returns -1, 0 or 1 depending on whether the amount is negative, zero or positive.
+func (m Money) Sign() int {
+	switch {
+	case m.micros < 0:
+		return -1
+	case m.micros > 0:
+		return 1
+	}
+	return 0
+}
+
+// Cmp compares two amounts, returning -1 if m < o, 0 if m == o, and 1 if m > o.
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.micros < o.micros:
+		return -1
+	case m.micros > o.micros:
+		return 1
+	}
+	return 0
+}
+
+// Add returns m + o.
+func (m Money) Add(o Money) Money {
+	return Money{m.micros + o.micros}
+}
+
+// Sub returns m - o.
+func (m Money) Sub(o Money) Money {
+	return Money{m.micros - o.micros}
+}
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return Money{-m.micros}
+}
+
+// Abs returns the absolute value of m.
+func (m Money) Abs() Money {
+	if m.micros < 0 {
+		return m.Neg()
+	}
+	return m
+}
+
+// MulInt returns m multiplied by a whole number, such as a quantity of shares. It
+// panics if the result is out of range.
+func (m Money) MulInt(n int64) Money {
+	p := m.micros * n
+	if n != 0 && (p/n != m.micros || (n == -1 && m.micros == math.MinInt64)) {
+		panic(fmt.Sprintf("Error: Money amount %s * %d out of range", m, n))
+	}
+	return Money{p}
+}
+
+// Mul returns m * o, rounded to six decimal places.
+func (m Money) Mul(o Money) Money {
+	r := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(m.micros), big.NewInt(o.micros)),
+		big.NewInt(moneyScale))
+	res, _ := roundRat(r)
+	return res
+}
+
+// MulFloat returns m multiplied by a factor, such as a percentage, rounded to six
+// decimal places.
+func (m Money) MulFloat(f float64) Money {
+	r := new(big.Rat).SetInt64(m.micros)
+	fr := new(big.Rat)
+	if fr.SetFloat64(f) == nil {
+		return Money{}
+	}
+	res, _ := roundRat(r.Mul(r, fr))
+	return res
+}
+
+// Div returns m / o, rounded to six decimal places. It panics if o is zero.
+func (m Money) Div(o Money) Money {
+	r := new(big.Rat).SetFrac(
+		new(big.Int).Mul(big.NewInt(m.micros), big.NewInt(moneyScale)),
+		big.NewInt(o.micros))
+	res, _ := roundRat(r)
+	return res
+}
+
+// DivInt returns m divided by a whole number, rounded to six decimal places. It
+// panics if n is zero.
+func (m Money) DivInt(n int64) Money {
+	res, _ := roundRat(big.NewRat(m.micros, n))
+	return res
+}
+
+// RoundTo rounds m to the nearest multiple of step, half away from zero. If step
+// is zero, m is returned unchanged.
+func (m Money) RoundTo(step Money) Money {
+	if step.micros == 0 {
+		return m
+	}
+	res, _ := roundRat(big.NewRat(m.micros, step.Abs().micros))
+	return Money{res.micros * step.Abs().micros}
+}
+
+// Round rounds m to the given number of decimal places, half away from zero.
+func (m Money) Round(places int) Money {
+	if places >= moneyDecimals {
+		return m
+	}
+	step := int64(1)
+	for i := places; i < moneyDecimals; i++ {
+		step *= 10
+	}
+	return m.RoundTo(Money{step})
+}
+
+// String formats the amount with as many decimal places as it needs, but at least
+// two (e.g., "10.50", "0.0001", "-3.25").
+func (m Money) String() string {
+	s := m.StringFixed(moneyDecimals)
+	trimmed := strings.TrimRight(s, "0")
+	if dot := strings.IndexByte(s, '.'); len(trimmed) < dot+3 {
+		trimmed = s[:dot+3]
+	}
+	return trimmed
+}
+
+// StringFixed formats the amount rounded to the given number of decimal places.
+func (m Money) StringFixed(places int) string {
+	if places > moneyDecimals {
+		places = moneyDecimals
+	}
+	if places < 0 {
+		places = 0
+	}
+
+	r := m.Round(places).micros
+	sign := ""
+	if r < 0 {
+		sign = "-"
+	}
+
+	// Work with the magnitude as an unsigned value, so the minimum int64 is handled
+	u := uint64(r)
+	if r < 0 {
+		u = uint64(-r)
+	}
+
+	whole := strconv.FormatUint(u/moneyScale, 10)
+	if places == 0 {
+		return sign + whole
+	}
+
+	frac := fmt.Sprintf("%06d", u%moneyScale)
+	return sign + whole + "." + frac[:places]
+}
+
+// MarshalJSON implements json.Marshaler, encoding the amount as a JSON number.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Both JSON numbers and strings holding
+// numbers are accepted, and null decodes to zero.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*m = Money{}
+		return nil
+	}
+
+	if len(data) >= 2 && data[0] == '"' && data[len(data)-1] == '"' {
+		data = data[1 : len(data)-1]
+	}
+	if len(data) == 0 {
+		return errors.New("Error: Empty money amount")
+	}
+
+	v, err := ParseMoney(string(data))
+	if err != nil {
+		return err
+	}
+	*m = v
+	return nil
+}
@@ -0,0 +1,177 @@
+package qapi_test
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/alexurquhart/qapi"
+)
+
+var (
+	maxMoney = qapi.MoneyFromMicros(math.MaxInt64)
+	minMoney = qapi.MoneyFromMicros(math.MinInt64)
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		s      string
+		micros int64
+	}{
+		{"10.25", 10250000},
+		{"-0.0001", -100},
+		{" 2.5 ", 2500000},
+		{"1.5E-05", 15},
+		{"1e3", 1000000000},
+		{"0.0000005", 1},
+		{"-0.0000005", -1},
+		{"0.00000049", 0},
+		{"0.0000015", 2},
+		{"9223372036854.775807", math.MaxInt64},
+		{"-9223372036854.775808", math.MinInt64},
+	}
+	for _, tt := range tests {
+		m, err := qapi.ParseMoney(tt.s)
+		if err != nil || m.Micros() != tt.micros {
+			t.Errorf("ParseMoney(%q) = %d micros %v, want %d", tt.s, m.Micros(), err, tt.micros)
+		}
+	}
+
+	for _, s := range []string{"", "abc", "1.2.3", "$10"} {
+		if _, err := qapi.ParseMoney(s); err == nil || !strings.Contains(err.Error(), "Invalid") {
+			t.Errorf("ParseMoney(%q) = %v, want an invalid amount error", s, err)
+		}
+	}
+	for _, s := range []string{"9223372036854.775808", "-9223372036854.7758085", "1e20"} {
+		if _, err := qapi.ParseMoney(s); err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("ParseMoney(%q) = %v, want an out of range error", s, err)
+		}
+	}
+}
+
+func TestMoneyString(t *testing.T) {
+	tests := []struct {
+		m    qapi.Money
+		want string
+	}{
+		{qapi.Money{}, "0.00"},
+		{qapi.MustParseMoney("10.5"), "10.50"},
+		{qapi.MustParseMoney("0.0001"), "0.0001"},
+		{qapi.MustParseMoney("-3.25"), "-3.25"},
+		{qapi.MustParseMoney("-0.000001"), "-0.000001"},
+		{qapi.MustParseMoney("1234567.891"), "1234567.891"},
+		{maxMoney, "9223372036854.775807"},
+		{minMoney, "-9223372036854.775808"},
+	}
+	for _, tt := range tests {
+		if got := tt.m.String(); got != tt.want {
+			t.Errorf("String of %d micros = %q, want %q", tt.m.Micros(), got, tt.want)
+		}
+	}
+}
+
+func TestMoneyStringFixed(t *testing.T) {
+	tests := []struct {
+		s      string
+		places int
+		want   string
+	}{
+		{"1.005", 2, "1.01"},
+		{"-1.005", 2, "-1.01"},
+		{"1.004999", 2, "1.00"},
+		{"2.5", 0, "3"},
+		{"-2.5", 0, "-3"},
+		{"0.1", 4, "0.1000"},
+		{"0.123456", 10, "0.123456"},
+		{"7.9", -1, "8"},
+	}
+	for _, tt := range tests {
+		if got := qapi.MustParseMoney(tt.s).StringFixed(tt.places); got != tt.want {
+			t.Errorf("StringFixed(%s, %d) = %q, want %q", tt.s, tt.places, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	type prices struct {
+		Price qapi.Money  `json:"price"`
+		Stop  qapi.Money  `json:"stop,omitzero"`
+		Limit *qapi.Money `json:"limit"`
+	}
+
+	in := prices{Price: qapi.MustParseMoney("123456789.123456")}
+	data, err := json.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if got, want := string(data), `{"price":123456789.123456,"limit":null}`; got != want {
+		t.Errorf("Marshal = %s, want %s", got, want)
+	}
+
+	var out prices
+	if err := json.Unmarshal(data, &out); err != nil || out.Price != in.Price || out.Limit != nil {
+		t.Errorf("Unmarshal(%s) = %+v %v, want %+v", data, out, err, in)
+	}
+
+	// Strings and nulls are accepted too
+	if err := json.Unmarshal([]byte(`{"price":"0.10","stop":null}`), &out); err != nil || out.Price.Micros() != 100000 || !out.Stop.IsZero() {
+		t.Errorf("Unmarshal of a string and null = %+v %v", out, err)
+	}
+
+	for _, bad := range []string{`{"price":""}`, `{"price":"abc"}`, `{"price":true}`, `{"price":1e30}`} {
+		if err := json.Unmarshal([]byte(bad), &out); err == nil {
+			t.Errorf("Unmarshal(%s): expected an error", bad)
+		}
+	}
+}
+
+func TestMoneyCmp(t *testing.T) {
+	tests := []struct {
+		a, b qapi.Money
+		want int
+	}{
+		{qapi.MustParseMoney("1.01"), qapi.MustParseMoney("1.02"), -1},
+		{qapi.MustParseMoney("-1"), qapi.MustParseMoney("-1.00"), 0},
+		{maxMoney, minMoney, 1},
+		{minMoney, maxMoney, -1},
+		{maxMoney, qapi.MustParseMoney("-1"), 1},
+		{minMoney, qapi.MustParseMoney("1"), -1},
+		{maxMoney, maxMoney, 0},
+		{minMoney, minMoney, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.a.Cmp(tt.b); got != tt.want {
+			t.Errorf("%s.Cmp(%s) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMoneyMulInt(t *testing.T) {
+	if got := qapi.MustParseMoney("10.25").MulInt(-300); got.String() != "-3075.00" {
+		t.Errorf("MulInt = %s, want -3075.00", got)
+	}
+	if got := minMoney.MulInt(1); got != minMoney {
+		t.Errorf("MulInt by 1 = %s, want %s", got, minMoney)
+	}
+
+	overflows := []struct {
+		m qapi.Money
+		n int64
+	}{
+		{maxMoney, 2},
+		{qapi.MustParseMoney("1000000"), 10000000},
+		{minMoney, -1},
+		{qapi.MoneyFromMicros(-1), math.MinInt64},
+	}
+	for _, tt := range overflows {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%d micros MulInt(%d) did not panic", tt.m.Micros(), tt.n)
+				}
+			}()
+			tt.m.MulInt(tt.n)
+		}()
+	}
+}
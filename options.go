@@ -0,0 +1,106 @@
+package qapi
+
+import (
+	"net/http"
+	"time"
+)
+
+// ClientOption customizes a client created with NewClient or NewClientFromStore.
+type ClientOption func(*Client)
+
+// WithHTTPClient makes the client send requests with the given HTTP client,
+// instead of a default one. Use this to configure proxies, TLS or tracing.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithTimeout sets a time limit for each HTTP request made by the client. If
+// a custom HTTP client is also given, a copy of it is made with the timeout set.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithLoginURL overrides the URL of the login server, which is normally chosen
+// by the practice flag. The URL must end with a slash (e.g., "http://localhost:8080/oauth2/").
+func WithLoginURL(u string) ClientOption {
+	return func(c *Client) {
+		c.loginURL = u
+	}
+}
+
+// WithAPIServer overrides the URL of the API server that is returned by the login
+// server. The URL must end with a slash (e.g., "http://localhost:8080/").
+func WithAPIServer(u string) ClientOption {
+	return func(c *Client) {
+		c.apiServer = u
+	}
+}
+
+// WithCredentials makes the client use previously saved login credentials,
+// without logging in. Since the time at which the access token expires is not
+// known, the session is refreshed once the server rejects the access token.
+func WithCredentials(creds LoginCredentials) ClientOption {
+	return func(c *Client) {
+		c.Credentials = creds
+		c.skipLogin = true
+	}
+}
+
+// WithoutLogin stops the client from logging in when it is created. It will log
+// in before the first API request is made instead.
+func WithoutLogin() ClientOption {
+	return func(c *Client) {
+		c.skipLogin = true
+	}
+}
+
+// WithTokenStore makes the client read its refresh token from the store before
+// every login, and save the replacement credentials to it afterwards. A refresh
+// token given to NewClient is used for the first login instead, and replaces the
+// stored one.
+func WithTokenStore(store TokenStore) ClientOption {
+	return func(c *Client) {
+		c.store = store
+	}
+}
+
+// WithCandleCache makes GetCandles consult the cache before the API. Only the
+// parts of the requested range that haven't been retrieved before are requested,
+// however many requests that takes, and the new candles are saved to the cache.
+// Use InvalidateCandles to have a range retrieved again.
+func WithCandleCache(cache CandleCache) ClientOption {
+	return func(c *Client) {
+		c.candleCache = cache
+	}
+}
+
+// WithRetryPolicy sets the policy used to retry failed requests. The default is
+// DefaultRetryPolicy.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *Client) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithOrderValidation makes PlaceOrder validate order requests before they are
+// sent. If the order has a price, the symbol is retrieved to check that the price
+// is a multiple of its tick size.
+func WithOrderValidation() ClientOption {
+	return func(c *Client) {
+		c.validate = true
+	}
+}
+
+// WithStrictEnums makes the client reject enumerated values that are not
+// documented by Questrade, in requests and in responses. By default they are
+// accepted, so that values added to the API in the future don't break existing
+// programs. Empty values are always accepted.
+func WithStrictEnums() ClientOption {
+	return func(c *Client) {
+		c.strictEnums = true
+	}
+}
@@ -0,0 +1,155 @@
+package qapi_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/qapitest"
+)
+
+// countingTransport counts the requests sent through it
+type countingTransport struct {
+	n int32
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&t.n, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// requestPaths returns the method and path of the requests a server received
+func requestPaths(srv *qapitest.Server) []string {
+	var p []string
+	for _, r := range srv.Requests() {
+		p = append(p, r.Method+" "+r.Path)
+	}
+	return p
+}
+
+// timeServer is an API server that answers the server time endpoint after a delay
+func timeServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		fmt.Fprint(w, `{"time":"2020-01-06T09:30:00-05:00"}`)
+	}))
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+
+	tr := &countingTransport{}
+	c, err := srv.NewClient(qapi.WithHTTPClient(&http.Client{Transport: tr}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.GetServerTime(); err != nil {
+		t.Fatalf("GetServerTime: %v", err)
+	}
+
+	if n := atomic.LoadInt32(&tr.n); n != 2 {
+		t.Errorf("custom transport sent %d requests, want 2", n)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	api := timeServer(300 * time.Millisecond)
+	defer api.Close()
+	creds := qapi.WithCredentials(qapi.LoginCredentials{AccessToken: "access", TokenType: "Bearer", ApiServer: api.URL + "/"})
+	noRetry := qapi.WithRetryPolicy(qapi.RetryPolicy{})
+
+	c, err := qapi.NewClient("", true, creds, noRetry, qapi.WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.GetServerTime(); err == nil {
+		t.Error("GetServerTime of a slow server: expected an error")
+	}
+
+	// A custom HTTP client is copied rather than modified
+	hc := &http.Client{}
+	c, err = qapi.NewClient("", true, creds, noRetry, qapi.WithHTTPClient(hc), qapi.WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.GetServerTime(); err == nil {
+		t.Error("GetServerTime of a slow server with a custom HTTP client: expected an error")
+	}
+	if hc.Timeout != 0 {
+		t.Errorf("custom HTTP client timeout = %v, want it unchanged", hc.Timeout)
+	}
+}
+
+func TestWithAPIServer(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+	api := timeServer(0)
+	defer api.Close()
+
+	c, err := srv.NewClient(qapi.WithAPIServer(api.URL + "/"))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if c.Credentials.ApiServer != api.URL+"/" {
+		t.Errorf("API server = %q, want %q", c.Credentials.ApiServer, api.URL+"/")
+	}
+
+	got, err := c.GetServerTime()
+	if want := time.Date(2020, 1, 6, 14, 30, 0, 0, time.UTC); err != nil || !got.Equal(want) {
+		t.Errorf("GetServerTime = %v %v, want %v", got, err, want)
+	}
+	if got := requestPaths(srv); len(got) != 1 || got[0] != "POST /oauth2/token" {
+		t.Errorf("login server requests = %v, want only the login", got)
+	}
+}
+
+func TestWithCredentials(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+
+	first, err := srv.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	// The saved session is used without logging in again
+	c, err := qapi.NewClient("", true, qapi.WithCredentials(first.Credentials))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.GetServerTime(); err != nil {
+		t.Fatalf("GetServerTime: %v", err)
+	}
+
+	want := []string{"POST /oauth2/token", "GET /v1/time"}
+	if got := requestPaths(srv); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestWithoutLogin(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+
+	c, err := srv.NewClient(qapi.WithoutLogin())
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if n := len(srv.Requests()); n != 0 {
+		t.Errorf("NewClient sent %d requests, want 0", n)
+	}
+
+	// The client logs in before its first request
+	if _, err := c.GetServerTime(); err != nil {
+		t.Fatalf("GetServerTime: %v", err)
+	}
+	want := []string{"POST /oauth2/token", "GET /v1/time"}
+	if got := requestPaths(srv); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
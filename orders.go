@@ -4,8 +4,30 @@ import (
 	"time"
 )
 
-// TODO - Populate this struct
+// OrderLeg is a single leg of a multi-leg strategy order.
+//
+// Ref: http://www.questrade.com/api/documentation/rest-operations/account-calls/accounts-id-orders
 type OrderLeg struct {
+	// Internal identifier of the leg.
+	ID int `json:"legId"`
+
+	// Symbol that follows Questrade symbology (e.g., "MSFT17Mar17C60.00").
+	Symbol string `json:"symbol"`
+
+	// Internal symbol identifier.
+	SymbolID int `json:"symbolId"`
+
+	// Leg quantity, relative to the quantity of the other legs.
+	LegRatioQuantity int `json:"legRatioQuantity"`
+
+	// Client view of the leg side (e.g., "BTO").
+	Side OrderSide `json:"side"`
+
+	// Average price of all executions received for this leg.
+	AvgExecPrice Money `json:"avgExecPrice"`
+
+	// Price of the last execution received for this leg.
+	LastExecPrice Money `json:"lastExecPrice"`
 }
 
 // Ref: http://www.questrade.com/api/documentSymation/rest-operations/account-calls/accounts-id-orders
@@ -32,16 +54,16 @@ type Order struct {
 	CanceledQuantity int `json:"canceledQuantity"`
 
 	// Client view of the order side (e.g., "Buy-To-Open").
-	Side string `json:"side"`
+	Side OrderSide `json:"side"`
 
 	// Order price type (e.g., "Market").
-	OrderType string `json:"orderType"`
+	OrderType OrderType `json:"orderType"`
 
 	// Limit price.
-	LimitPrice float32 `json:"limitPrice"`
+	LimitPrice Money `json:"limitPrice"`
 
 	// Stop price.
-	StopPrice float32 `json:"stopPrice"`
+	StopPrice Money `json:"stopPrice"`
 
 	// Specifies all-or-none special instruction.
 	IsAllOrNone bool `json:"isAllOrNone"`
@@ -56,22 +78,22 @@ type Order struct {
 	MinQuantity int `json:"minQuantity"`
 
 	// Average price of all executions received for this order.
-	AvgExecPrice float32 `json:"avgExecPrice"`
+	AvgExecPrice Money `json:"avgExecPrice"`
 
 	// Price of the last execution received for the order in question.
-	LastExecPrice float32 `json:"lastExecPrice"`
+	LastExecPrice Money `json:"lastExecPrice"`
 
 	// See enumerations for all allowed values
 	Source string `json:"source"`
 
 	// See Order Time In Force section for all allowed values.
-	TimeInForce string `json:"timeInForce"`
+	TimeInForce TimeInForce `json:"timeInForce"`
 
 	// Good-Till-Date marker and date parameter
-	GtdDate *time.Time `json:"gtdDate",string`
+	GtdDate *time.Time `json:"gtdDate"`
 
 	// See Order State section for all allowed values.
-	State string `json:"state"`
+	State OrderState `json:"state"`
 
 	// Human readable order rejection reason message.
 	ClientReasonStr string `json:"clientReasonStr"`
@@ -80,19 +102,19 @@ type Order struct {
 	ChainID int `json:"chainId"`
 
 	// Order creation time.
-	CreationTime *time.Time `json:"creationTime",string`
+	CreationTime *time.Time `json:"creationTime"`
 
 	// Time of the last update.
-	UpdateTime *time.Time `json:"updateTime",string`
+	UpdateTime *time.Time `json:"updateTime"`
 
 	// Notes that may have been manually added by Questrade staff.
 	Notes string `json:"notes"`
 
 	// See enumerations for all allowed values
-	PrimaryRoute string `json:"primaryRoute"`
+	PrimaryRoute OrderRoute `json:"primaryRoute"`
 
 	// See enumerations for all allowed values
-	SecondaryRoute string `json:"secondaryRoute"`
+	SecondaryRoute OrderRoute `json:"secondaryRoute"`
 
 	// Order route name.
 	OrderRoute string `json:"orderRoute"`
@@ -101,7 +123,7 @@ type Order struct {
 	VenueHoldingOrder string `json:"venueHoldingOrder"`
 
 	// Total commission amount charged for this order.
-	CommissionCharged float32 `json:"commissionCharged"`
+	CommissionCharged Money `json:"commissionCharged"`
 
 	// Identifier assigned to this order by exchange where it was routed.
 	ExchangeOrderID string `json:"exchangeOrderId"`
@@ -119,22 +141,22 @@ type Order struct {
 	UserID int `json:"userId"`
 
 	// Commission for placing the order via the Trade Desk over the phone.
-	PlacementCommission float32 `json:"placementCommission"`
+	PlacementCommission Money `json:"placementCommission"`
 
 	// List of OrderLeg elements.
 	Legs []OrderLeg `json:"legs"`
 
 	// Multi-leg strategy to which the order belongs.
-	StrategyType string `json:"strategyType"`
+	StrategyType StrategyType `json:"strategyType"`
 
 	// Stop price at which order was triggered.
-	TriggerStopPrice float32 `json:"triggerStopPrice"`
+	TriggerStopPrice Money `json:"triggerStopPrice"`
 
 	// Internal identifier of the order group.
 	OrderGroupID int `json:"orderGroupId"`
 
 	// Bracket Order class. Primary, Profit or Loss.
-	OrderClass string `json:"orderClass"`
+	OrderClass OrderClass `json:"orderClass"`
 }
 
 // Ref: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders
@@ -155,12 +177,15 @@ type OrderRequest struct {
 	IcebergQuantity int `json:"icebergQuantity,omitempty"`
 
 	// Limit price.
-	LimitPrice float32 `json:"limitPrice,omitempty"`
+	LimitPrice Money `json:"limitPrice,omitzero"`
 
 	// Stop price.
-	StopPrice float32 `json:"stopPrice,omitempty"`
+	StopPrice Money `json:"stopPrice,omitzero"`
+
+	TimeInForce TimeInForce `json:"timeInForce"`
 
-	TimeInForce string `json:"timeInForce"`
+	// Good-Till-Date marker and date parameter. Required if TimeInForce is GoodTillDate.
+	GtdDate *time.Time `json:"gtdDate,omitempty"`
 
 	// Identifies whether the all-or-none instruction is enabled.
 	IsAllOrNone bool `json:"isAllOrNone"`
@@ -171,41 +196,192 @@ type OrderRequest struct {
 	IsLimitOffsetInDollar bool `json:"isLimitOffsetInDollar"`
 
 	// Order type (e.g., "Market").
-	OrderType string `json:"orderType"`
+	OrderType OrderType `json:"orderType"`
 
 	// Order side (e.g., "Buy").
-	Action string `json:"action"`
+	Action OrderSide `json:"action"`
 
 	// Secondary order route (e.g., "NYSE").
-	SecondaryRoute string `json:"secondaryRoute"`
+	SecondaryRoute OrderRoute `json:"secondaryRoute"`
 
 	// Primary order route (e.g., "AUTO").
-	PrimaryRoute string `json:"primaryRoute"`
+	PrimaryRoute OrderRoute `json:"primaryRoute"`
 }
 
 // Ref: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-impact
 type OrderImpact struct {
 	// Estimate of commissions to be charged on the order.
-	EstimatedCommissions float32 `json:"estimatedCommissions"`
+	EstimatedCommissions Money `json:"estimatedCommissions"`
 
 	// Estimate of change in buying power from the order.
-	BuyingPowerEffect float32 `json:"buyingPowerEffect"`
+	BuyingPowerEffect Money `json:"buyingPowerEffect"`
 
 	// Estimate of buying power in which order will result.
-	BuyingPowerResult float32 `json:"buyingPowerResult"`
+	BuyingPowerResult Money `json:"buyingPowerResult"`
 
 	// Estimate of change in maintenance excess from the order.
-	MaintExcessEffect float32 `json:"maintExcessEffect"`
+	MaintExcessEffect Money `json:"maintExcessEffect"`
 
 	// Estimate of maintenance excess in which the order will result.
-	MaintExcessResult float32 `json:"maintExcessResult"`
+	MaintExcessResult Money `json:"maintExcessResult"`
 
 	// Client view of the order side (e.g., "Buy-To-Open").
-	Side string `json:"side"`
+	Side OrderSide `json:"side"`
 
 	// Estimate of the order execution value.
 	TradeValueCalculation string `json:"tradeValueCalculation"`
 
 	// Estimated average fill price.
-	Price float32 `json:"price"`
+	Price Money `json:"price"`
+}
+
+// StrategyLeg is a leg of a multi-leg strategy order request.
+type StrategyLeg struct {
+	// Internal symbol identifier of the leg.
+	SymbolID int `json:"symbolId"`
+
+	// Leg side (e.g., "BTO").
+	Action OrderSide `json:"action"`
+
+	// Quantity of the leg.
+	LegQuantity int `json:"legQuantity"`
+}
+
+// StrategyOrderRequest is a request to place a multi-leg strategy order, such as a
+// vertical spread, straddle, collar, or a custom combination of legs.
+//
+// Ref: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-strategy
+type StrategyOrderRequest struct {
+	// Account number against which order is being submitted.
+	AccountID string `json:"accountNumber"`
+
+	// Optional – order id of the order to be replaced.
+	OrderID int `json:"orderId,omitempty"`
+
+	// Internal symbol identifier of the underlying.
+	SymbolID int `json:"symbolId"`
+
+	// Type of the strategy (e.g., "VerticalCallSpread", "Straddle", "Collar", "Custom").
+	StrategyType StrategyType `json:"strategyType"`
+
+	// Order type (e.g., "Limit").
+	OrderType OrderType `json:"orderType"`
+
+	// Limit price for the strategy as a whole.
+	LimitPrice Money `json:"limitPrice,omitzero"`
+
+	TimeInForce TimeInForce `json:"timeInForce"`
+
+	// Identifies whether the all-or-none instruction is enabled.
+	IsAllOrNone bool `json:"isAllOrNone"`
+
+	// Identifies whether the anonymous instruction is enabled.
+	IsAnonymous bool `json:"isAnonymous"`
+
+	// Secondary order route (e.g., "NYSE").
+	SecondaryRoute OrderRoute `json:"secondaryRoute"`
+
+	// Primary order route (e.g., "AUTO").
+	PrimaryRoute OrderRoute `json:"primaryRoute"`
+
+	// Legs of the strategy.
+	Legs []StrategyLeg `json:"legs"`
+}
+
+// BracketComponent is one of the orders in a bracket order request.
+type BracketComponent struct {
+	// Optional – order id of the component to be replaced.
+	OrderID int `json:"orderId,omitempty"`
+
+	// Order quantity.
+	Quantity int `json:"quantity"`
+
+	// Order side (e.g., "Buy").
+	Action OrderSide `json:"action"`
+
+	// Limit price.
+	LimitPrice Money `json:"limitPrice,omitzero"`
+
+	// Stop price.
+	StopPrice Money `json:"stopPrice,omitzero"`
+
+	// Order type (e.g., "Limit").
+	OrderType OrderType `json:"orderType"`
+
+	TimeInForce TimeInForce `json:"timeInForce"`
+
+	// Bracket order class (e.g., "Primary", "Profit" or "Loss").
+	OrderClass OrderClass `json:"orderClass"`
+}
+
+// BracketOrderRequest is a request to place a bracket order - a primary order,
+// along with a profit taking order and a stop loss order that are triggered once
+// the primary order is filled.
+//
+// Ref: http://www.questrade.com/api/documentation/rest-operations/order-calls/accounts-id-orders-bracket
+type BracketOrderRequest struct {
+	// Account number against which order is being submitted.
+	AccountID string `json:"accountNumber"`
+
+	// Internal symbol identifier.
+	SymbolID int `json:"symbolId"`
+
+	// Secondary order route (e.g., "NYSE").
+	SecondaryRoute OrderRoute `json:"secondaryRoute"`
+
+	// Primary order route (e.g., "AUTO").
+	PrimaryRoute OrderRoute `json:"primaryRoute"`
+
+	TimeInForce TimeInForce `json:"timeInForce"`
+
+	// The primary, profit and loss orders.
+	Components []BracketComponent `json:"components"`
+}
+
+// BracketOrder groups the orders that make up a bracket order. Orders that are
+// not part of the bracket are nil.
+type BracketOrder struct {
+	// Internal identifier of the order group.
+	GroupID int
+
+	// The order that opens the position.
+	Primary *Order
+
+	// The order that takes profit on the position.
+	Profit *Order
+
+	// The order that stops losses on the position.
+	Loss *Order
+}
+
+// GroupBracketOrders groups orders into bracket orders by their order group. Orders
+// that don't belong to a group are left out.
+func GroupBracketOrders(orders []Order) []BracketOrder {
+	var brackets []BracketOrder
+	index := map[int]int{}
+
+	for k := range orders {
+		o := &orders[k]
+		if o.OrderGroupID == 0 {
+			continue
+		}
+
+		i, ok := index[o.OrderGroupID]
+		if !ok {
+			i = len(brackets)
+			index[o.OrderGroupID] = i
+			brackets = append(brackets, BracketOrder{GroupID: o.OrderGroupID})
+		}
+
+		switch o.OrderClass {
+		case OrderClassPrimary:
+			brackets[i].Primary = o
+		case OrderClassProfit:
+			brackets[i].Profit = o
+		case OrderClassLoss:
+			brackets[i].Loss = o
+		}
+	}
+
+	return brackets
 }
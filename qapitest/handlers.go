@@ -0,0 +1,586 @@
+package qapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// Questrade error codes returned by the server
+// Ref: http://www.questrade.com/api/documentation/error-handling
+const (
+	codeInvalidEndpoint = 1001
+	codeInvalidArgument = 1002
+	codeInvalidToken    = 1017
+)
+
+// route maps a request method and path to a handler. Path segments of "*" match
+// any non-empty segment, which is passed to the handler as a parameter.
+type route struct {
+	method  string
+	pattern string
+	handler func(s *Server, w http.ResponseWriter, r *http.Request, params []string)
+}
+
+// routes lists the server's endpoints. Earlier routes take precedence.
+var routes = []route{
+	{"POST", "/oauth2/token", (*Server).handleToken},
+	{"POST", "/oauth2/revoke", (*Server).handleRevoke},
+	{"GET", "/v1/time", (*Server).handleTime},
+	{"GET", "/v1/accounts", (*Server).handleAccounts},
+	{"GET", "/v1/accounts/*/balances", (*Server).handleBalances},
+	{"GET", "/v1/accounts/*/positions", (*Server).handlePositions},
+	{"GET", "/v1/accounts/*/executions", (*Server).handleExecutions},
+	{"GET", "/v1/accounts/*/orders", (*Server).handleOrders},
+	{"POST", "/v1/accounts/*/orders/", (*Server).handlePlaceOrder},
+	{"POST", "/v1/accounts/*/orders/impact", (*Server).handleOrderImpact},
+	{"POST", "/v1/accounts/*/orders/*/impact", (*Server).handleOrderImpact},
+	{"POST", "/v1/accounts/*/orders/*", (*Server).handlePlaceOrder},
+	{"DELETE", "/v1/accounts/*/orders/*", (*Server).handleDeleteOrder},
+	{"GET", "/v1/symbols", (*Server).handleSymbols},
+	{"GET", "/v1/symbols/search", (*Server).handleSearch},
+	{"GET", "/v1/markets", (*Server).handleMarkets},
+	{"GET", "/v1/markets/quotes", (*Server).handleQuotes},
+	{"GET", "/v1/markets/quotes/*", (*Server).handleQuotes},
+	{"GET", "/v1/markets/candles/*", (*Server).handleCandles},
+}
+
+// match determines whether the route handles a request, and returns the path parameters
+func (rt route) match(r *http.Request) ([]string, bool) {
+	if r.Method != rt.method {
+		return nil, false
+	}
+
+	pattern := strings.Split(rt.pattern, "/")
+	segments := strings.Split(r.URL.Path, "/")
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+
+	var params []string
+	for k, p := range pattern {
+		switch {
+		case p == "*" && segments[k] != "":
+			params = append(params, segments[k])
+		case p != segments[k]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// dispatch sends a request to the handler of its route. API requests must carry
+// a valid access token.
+func (s *Server) dispatch(w http.ResponseWriter, r *http.Request) {
+	for _, rt := range routes {
+		params, ok := rt.match(r)
+		if !ok {
+			continue
+		}
+
+		if strings.HasPrefix(rt.pattern, "/v1/") && !s.authorized(r) {
+			writeError(w, http.StatusUnauthorized, codeInvalidToken, "Access token is invalid")
+			return
+		}
+		rt.handler(s, w, r, params)
+		return
+	}
+
+	writeError(w, http.StatusNotFound, codeInvalidEndpoint, "Invalid endpoint")
+}
+
+// intercept records every request, and fails the ones that match a fault
+func (s *Server) intercept(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidArgument, err.Error())
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		s.mu.Lock()
+		s.requests = append(s.requests, Request{
+			Method: r.Method,
+			Path:   r.URL.Path,
+			Query:  r.URL.Query(),
+			Body:   body,
+		})
+		f, failed := s.fault(r)
+		s.mu.Unlock()
+
+		if !failed {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		status := f.StatusCode
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+		if f.RetryAfter > 0 {
+			secs := (f.RetryAfter + time.Second - 1) / time.Second
+			w.Header().Set("Retry-After", strconv.Itoa(int(secs)))
+		}
+		writeError(w, status, f.Code, f.Message)
+	})
+}
+
+// authorized determines whether a request carries a valid access token
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.accessTokens[token]
+}
+
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request, params []string) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != s.refreshToken {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, "Bad Request")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.issueToken())
+}
+
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request, params []string) {
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	delete(s.accessTokens, r.PostForm.Get("token"))
+	s.mu.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleTime(w http.ResponseWriter, r *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, struct {
+		Time time.Time `json:"time"`
+	}{s.now()})
+}
+
+func (s *Server) handleAccounts(w http.ResponseWriter, r *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, struct {
+		UserID   int            `json:"userId"`
+		Accounts []qapi.Account `json:"accounts"`
+	}{s.userID, append([]qapi.Account{}, s.accounts...)})
+}
+
+// account locks the server and checks that an account exists. If it doesn't, an
+// error is sent, the lock is released and false is returned.
+func (s *Server) account(w http.ResponseWriter, number string) (string, bool) {
+	s.mu.Lock()
+	if !s.hasAccount(number) {
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, "Invalid account number: "+number)
+		return "", false
+	}
+	return number, true
+}
+
+func (s *Server) handleBalances(w http.ResponseWriter, r *http.Request, params []string) {
+	number, ok := s.account(w, params[0])
+	if !ok {
+		return
+	}
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, s.balances[number])
+}
+
+func (s *Server) handlePositions(w http.ResponseWriter, r *http.Request, params []string) {
+	number, ok := s.account(w, params[0])
+	if !ok {
+		return
+	}
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, struct {
+		Positions []qapi.Position `json:"positions"`
+	}{append([]qapi.Position{}, s.positions[number]...)})
+}
+
+func (s *Server) handleExecutions(w http.ResponseWriter, r *http.Request, params []string) {
+	number, ok := s.account(w, params[0])
+	if !ok {
+		return
+	}
+	defer s.mu.Unlock()
+
+	rng, err := timeRange(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	exec := []qapi.Execution{}
+	for _, e := range s.executions[number] {
+		if rng.Contains(e.Timestamp) {
+			exec = append(exec, e)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Executions []qapi.Execution `json:"executions"`
+	}{exec})
+}
+
+func (s *Server) handleOrders(w http.ResponseWriter, r *http.Request, params []string) {
+	number, ok := s.account(w, params[0])
+	if !ok {
+		return
+	}
+	defer s.mu.Unlock()
+
+	orders := []qapi.Order{}
+
+	// Orders requested by ID are returned regardless of their state and time
+	if ids := r.URL.Query().Get("ids"); ids != "" {
+		for _, id := range parseIDs(ids) {
+			if o := s.findOrder(number, id); o != nil {
+				orders = append(orders, *o)
+			}
+		}
+	} else {
+		rng, err := timeRange(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, codeInvalidArgument, err.Error())
+			return
+		}
+
+		filter := qapi.OrderStateFilter(r.URL.Query().Get("stateFilter"))
+		for _, o := range s.orders[number] {
+			if o.CreationTime != nil && !rng.Contains(*o.CreationTime) {
+				continue
+			}
+			if (filter == qapi.OrderFilterOpen && !isOpen(o.State)) ||
+				(filter == qapi.OrderFilterClosed && isOpen(o.State)) {
+				continue
+			}
+			orders = append(orders, o)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Orders []qapi.Order `json:"orders"`
+	}{orders})
+}
+
+func (s *Server) handlePlaceOrder(w http.ResponseWriter, r *http.Request, params []string) {
+	var req qapi.OrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	number, ok := s.account(w, params[0])
+	if !ok {
+		return
+	}
+	defer s.mu.Unlock()
+
+	if req.SymbolID <= 0 || req.Quantity <= 0 {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, "Invalid symbol or quantity")
+		return
+	}
+
+	now := s.now()
+	s.orderSeq++
+	o := qapi.Order{
+		ID:                    s.orderSeq,
+		Symbol:                s.symbols[req.SymbolID].Symbol,
+		SymbolID:              req.SymbolID,
+		TotalQuantity:         req.Quantity,
+		OpenQuantity:          req.Quantity,
+		Side:                  req.Action,
+		OrderType:             req.OrderType,
+		LimitPrice:            req.LimitPrice,
+		StopPrice:             req.StopPrice,
+		IsAllOrNone:           req.IsAllOrNone,
+		IsAnonymous:           req.IsAnonymous,
+		IcebergQuantity:       req.IcebergQuantity,
+		TimeInForce:           req.TimeInForce,
+		GtdDate:               req.GtdDate,
+		State:                 qapi.OrderStateAccepted,
+		ChainID:               s.orderSeq,
+		CreationTime:          &now,
+		UpdateTime:            &now,
+		PrimaryRoute:          req.PrimaryRoute,
+		SecondaryRoute:        req.SecondaryRoute,
+		IsLimitOffsetInDollar: req.IsLimitOffsetInDollar,
+		UserID:                s.userID,
+	}
+
+	// A replacement order continues the chain of the order it replaces
+	if len(params) > 1 {
+		id := params[1]
+		orderID, _ := strconv.Atoi(id)
+		orig := s.findOrder(number, orderID)
+		if orig == nil || !isOpen(orig.State) {
+			writeError(w, http.StatusBadRequest, codeInvalidArgument, "Order can't be replaced: "+id)
+			return
+		}
+		orig.State = qapi.OrderStateReplaced
+		orig.UpdateTime = &now
+		o.ChainID = orig.ChainID
+	}
+
+	s.orders[number] = append(s.orders[number], o)
+
+	writeJSON(w, http.StatusOK, struct {
+		OrderID int          `json:"orderId"`
+		Orders  []qapi.Order `json:"orders"`
+	}{o.ID, []qapi.Order{o}})
+}
+
+func (s *Server) handleOrderImpact(w http.ResponseWriter, r *http.Request, params []string) {
+	var req qapi.OrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, err.Error())
+		return
+	}
+
+	number, ok := s.account(w, params[0])
+	if !ok {
+		return
+	}
+	defer s.mu.Unlock()
+
+	// Limit orders are valued at their limit, and other orders at the quote
+	price := req.LimitPrice
+	if price.IsZero() {
+		q := s.quotes[req.SymbolID]
+		price = q.AskPrice
+		if !req.Action.IsBuy() {
+			price = q.BidPrice
+		}
+	}
+
+	value := price.MulInt(int64(req.Quantity))
+	effect := value
+	if req.Action.IsBuy() {
+		effect = value.Neg()
+	}
+
+	impact := qapi.OrderImpact{
+		BuyingPowerEffect:     effect,
+		MaintExcessEffect:     effect,
+		Side:                  req.Action,
+		TradeValueCalculation: fmt.Sprintf("%d x $%s = $%s", req.Quantity, price, value),
+		Price:                 price,
+	}
+	if b := s.balances[number].CombinedBalances; len(b) > 0 {
+		impact.BuyingPowerResult = b[0].BuyingPower.Add(effect)
+		impact.MaintExcessResult = b[0].MaintenanceExcess.Add(effect)
+	}
+
+	writeJSON(w, http.StatusOK, impact)
+}
+
+func (s *Server) handleDeleteOrder(w http.ResponseWriter, r *http.Request, params []string) {
+	number, ok := s.account(w, params[0])
+	if !ok {
+		return
+	}
+	defer s.mu.Unlock()
+
+	id, _ := strconv.Atoi(params[1])
+	o := s.findOrder(number, id)
+	if o == nil {
+		writeError(w, http.StatusNotFound, codeInvalidArgument, "Order not found: "+params[1])
+		return
+	}
+	if !isOpen(o.State) {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, "Order can't be canceled: "+params[1])
+		return
+	}
+
+	now := s.now()
+	o.State = qapi.OrderStateCanceled
+	o.CanceledQuantity = o.OpenQuantity
+	o.OpenQuantity = 0
+	o.UpdateTime = &now
+
+	writeJSON(w, http.StatusOK, struct {
+		OrderID int `json:"orderId"`
+	}{id})
+}
+
+func (s *Server) handleSymbols(w http.ResponseWriter, r *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	syms := []qapi.Symbol{}
+	for _, id := range parseIDs(r.URL.Query().Get("ids")) {
+		if sym, ok := s.symbols[id]; ok {
+			syms = append(syms, sym)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Symbols []qapi.Symbol `json:"symbols"`
+	}{syms})
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request, params []string) {
+	prefix := strings.ToUpper(r.URL.Query().Get("prefix"))
+	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	results := []qapi.SymbolSearchResult{}
+	for _, sym := range s.symbols {
+		if !strings.HasPrefix(strings.ToUpper(sym.Symbol), prefix) {
+			continue
+		}
+		results = append(results, qapi.SymbolSearchResult{
+			Symbol:          sym.Symbol,
+			SymbolID:        sym.SymbolID,
+			Description:     sym.Description,
+			SecurityType:    sym.SecurityType,
+			ListingExchange: sym.ListingExchange,
+			IsQuotable:      sym.IsQuotable,
+			IsTradable:      true,
+			Currency:        sym.Currency,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Symbol != results[j].Symbol {
+			return results[i].Symbol < results[j].Symbol
+		}
+		return results[i].SymbolID < results[j].SymbolID
+	})
+
+	if offset > len(results) {
+		offset = len(results)
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Symbols []qapi.SymbolSearchResult `json:"symbols"`
+	}{results[offset:]})
+}
+
+func (s *Server) handleMarkets(w http.ResponseWriter, r *http.Request, params []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, struct {
+		Markets []qapi.Market `json:"markets"`
+	}{append([]qapi.Market{}, s.markets...)})
+}
+
+func (s *Server) handleQuotes(w http.ResponseWriter, r *http.Request, params []string) {
+	ids := r.URL.Query().Get("ids")
+	if len(params) > 0 {
+		ids = params[0]
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quotes := []qapi.Quote{}
+	for _, id := range parseIDs(ids) {
+		if q, ok := s.quotes[id]; ok {
+			quotes = append(quotes, q)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Quotes []qapi.Quote `json:"quotes"`
+	}{quotes})
+}
+
+// handleCandles returns the candles that start within the requested range. If
+// there are more than the server's limit, the latest candles are left out.
+func (s *Server) handleCandles(w http.ResponseWriter, r *http.Request, params []string) {
+	id, err := strconv.Atoi(params[0])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, "Invalid symbol ID")
+		return
+	}
+
+	rng, err := timeRange(r)
+	if err != nil || rng.Start.IsZero() || rng.End.IsZero() {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, "startTime and endTime are required")
+		return
+	}
+
+	interval := qapi.CandleInterval(r.URL.Query().Get("interval"))
+	if interval == "" {
+		writeError(w, http.StatusBadRequest, codeInvalidArgument, "interval is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	candles := []qapi.Candlestick{}
+	for _, c := range s.candles[candleKey{id, interval}] {
+		if len(candles) >= s.maxCandles {
+			break
+		}
+		if rng.Contains(c.Start) {
+			candles = append(candles, c)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, struct {
+		Candles []qapi.Candlestick `json:"candles"`
+	}{candles})
+}
+
+// timeRange parses the optional startTime and endTime query parameters
+func timeRange(r *http.Request) (qapi.TimeRange, error) {
+	var rng qapi.TimeRange
+	var err error
+
+	if v := r.URL.Query().Get("startTime"); v != "" {
+		if rng.Start, err = time.Parse(time.RFC3339, v); err != nil {
+			return rng, err
+		}
+	}
+	if v := r.URL.Query().Get("endTime"); v != "" {
+		if rng.End, err = time.Parse(time.RFC3339, v); err != nil {
+			return rng, err
+		}
+	}
+	return rng, nil
+}
+
+// parseIDs parses a comma separated list of ID's, skipping invalid ones
+func parseIDs(s string) []int {
+	var ids []int
+	for _, v := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
@@ -0,0 +1,284 @@
+package qapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"sync"
+)
+
+// redacted replaces secrets in recorded interactions
+const redacted = "REDACTED"
+
+// recordedHeaders are the response headers that are kept in recordings. Rate
+// limit headers are left out, so that replaying doesn't depend on the time.
+var recordedHeaders = []string{"Content-Type", "Retry-After"}
+
+// Interaction is a request and its response, as recorded by a Recorder.
+type Interaction struct {
+	// HTTP method of the request (e.g., "GET").
+	Method string `json:"method"`
+
+	// Path of the request (e.g., "/v1/accounts/12345678/positions").
+	Endpoint string `json:"endpoint"`
+
+	// Encoded query string of the request, with the parameters sorted by name.
+	Query string `json:"query,omitempty"`
+
+	// Body of the request. Form encoded bodies, which carry tokens, are not recorded.
+	RequestBody string `json:"requestBody,omitempty"`
+
+	// HTTP status code of the response.
+	StatusCode int `json:"statusCode"`
+
+	// Headers of the response.
+	Header http.Header `json:"header,omitempty"`
+
+	// Body of the response.
+	Body string `json:"body"`
+}
+
+// matches determines whether the interaction was recorded for a request
+func (i Interaction) matches(req *http.Request) bool {
+	return i.Method == req.Method && i.Endpoint == req.URL.Path && i.Query == req.URL.Query().Encode()
+}
+
+// Recorder is an http.RoundTripper that sends requests with another transport,
+// and records them along with their responses. Authorization headers and form
+// encoded request bodies are never recorded, and access and refresh tokens are
+// removed from login responses. To record a session, give the client an HTTP
+// client that uses the recorder:
+//
+//	rec := qapitest.NewRecorder(nil)
+//	client, err := qapi.NewClient(token, true, qapi.WithHTTPClient(&http.Client{Transport: rec}))
+//	...
+//	err = rec.Save("testdata/positions.json")
+type Recorder struct {
+	// Transport used to send requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// If set, Redact is called on every interaction before it is recorded, to
+	// remove other sensitive data such as account numbers.
+	Redact func(*Interaction)
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder creates a recorder that sends requests with the given transport,
+// or with http.DefaultTransport if it is nil.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{Transport: transport}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := Interaction{
+		Method:   req.Method,
+		Endpoint: req.URL.Path,
+		Query:    req.URL.Query().Encode(),
+	}
+
+	if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		// The request is passed on, so it is copied rather than modified
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		if mt, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mt != "application/x-www-form-urlencoded" {
+			i.RequestBody = string(body)
+		}
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	i.StatusCode = res.StatusCode
+	i.Body = redactTokens(body)
+	for _, h := range recordedHeaders {
+		if v := res.Header.Get(h); v != "" {
+			if i.Header == nil {
+				i.Header = http.Header{}
+			}
+			i.Header.Set(h, v)
+		}
+	}
+
+	if r.Redact != nil {
+		r.Redact(&i)
+	}
+
+	r.mu.Lock()
+	r.interactions = append(r.interactions, i)
+	r.mu.Unlock()
+
+	return res, nil
+}
+
+// Interactions returns the interactions recorded so far, in the order the
+// responses were received.
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.interactions...)
+}
+
+// Save writes the recorded interactions to a fixture file, which can be loaded
+// with LoadReplayer.
+func (r *Recorder) Save(path string) error {
+	data, err := json.MarshalIndent(r.Interactions(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// redactTokens removes the access and refresh tokens from a login response. Other
+// response bodies are returned unchanged.
+func redactTokens(body []byte) string {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(body, &fields) != nil {
+		return string(body)
+	}
+
+	changed := false
+	for _, k := range []string{"access_token", "refresh_token"} {
+		if _, ok := fields[k]; ok {
+			fields[k] = json.RawMessage(`"` + redacted + `"`)
+			changed = true
+		}
+	}
+	if !changed {
+		return string(body)
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return string(body)
+	}
+	return string(data)
+}
+
+// Replayer is an http.RoundTripper that answers requests with recorded responses,
+// without using the network. A request is answered with the first unused
+// interaction that has the same method, endpoint and query, regardless of the
+// host it was sent to. Requests without a recorded response are answered with
+// HTTP 404.
+//
+//	rep, err := qapitest.LoadReplayer("testdata/positions.json")
+//	client, err := qapi.NewClient("", true, qapi.WithHTTPClient(&http.Client{Transport: rep}))
+type Replayer struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	used         []bool
+}
+
+// NewReplayer creates a replayer that answers requests with the given interactions.
+func NewReplayer(interactions []Interaction) *Replayer {
+	return &Replayer{
+		interactions: append([]Interaction(nil), interactions...),
+		used:         make([]bool, len(interactions)),
+	}
+}
+
+// LoadReplayer creates a replayer from a fixture file written by Recorder.Save.
+func LoadReplayer(path string) (*Replayer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("Error: Invalid fixture file %s: %v", path, err)
+	}
+	return NewReplayer(interactions), nil
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for k, i := range r.interactions {
+		if r.used[k] || !i.matches(req) {
+			continue
+		}
+		r.used[k] = true
+
+		header := http.Header{}
+		for h, v := range i.Header {
+			header[h] = append([]string(nil), v...)
+		}
+		return newResponse(req, i.StatusCode, header, i.Body), nil
+	}
+
+	endpoint := req.URL.Path
+	if req.URL.RawQuery != "" {
+		endpoint += "?" + req.URL.RawQuery
+	}
+
+	body, _ := json.Marshal(struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{codeInvalidEndpoint, "No recorded response for " + req.Method + " " + endpoint})
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	return newResponse(req, http.StatusNotFound, header, string(body)), nil
+}
+
+// Unused returns the interactions that haven't been replayed yet. Tests can use
+// it to check that every recorded request was made.
+func (r *Replayer) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unused []Interaction
+	for k, i := range r.interactions {
+		if !r.used[k] {
+			unused = append(unused, i)
+		}
+	}
+	return unused
+}
+
+// newResponse builds a response to a request
+func newResponse(req *http.Request, status int, header http.Header, body string) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader([]byte(body))),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
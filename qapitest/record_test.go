@@ -0,0 +1,121 @@
+package qapitest_test
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/qapitest"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(qapi.Account{Number: "12345678", Type: qapi.AccountMargin})
+	srv.SetPositions("12345678", []qapi.Position{{Symbol: "AAPL", SymbolID: 8049, OpenQuantity: 10}})
+
+	start := time.Date(2020, 1, 6, 14, 30, 0, 0, time.UTC)
+	end := start.Add(3 * time.Minute)
+	candles := []qapi.Candlestick{}
+	for s := start; s.Before(end); s = s.Add(time.Minute) {
+		candles = append(candles, qapi.Candlestick{Start: s, End: s.Add(time.Minute), Close: qapi.MustParseMoney("150.00")})
+	}
+	srv.SetCandles(8049, qapi.IntervalOneMinute, candles)
+
+	// Record a session against the fake server
+	rec := qapitest.NewRecorder(nil)
+	c, err := srv.NewClient(qapi.WithHTTPClient(&http.Client{Transport: rec}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	if _, err := c.GetPositions("12345678"); err != nil {
+		t.Fatalf("GetPositions: %v", err)
+	}
+	if _, err := c.GetCandles(8049, start, end, qapi.IntervalOneMinute); err != nil {
+		t.Fatalf("GetCandles: %v", err)
+	}
+	if _, err := c.PlaceOrder(qapi.OrderRequest{
+		AccountID:   "12345678",
+		SymbolID:    8049,
+		Quantity:    10,
+		OrderType:   qapi.OrderTypeMarket,
+		TimeInForce: qapi.TimeInForceDay,
+		Action:      qapi.SideSell,
+	}); err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	if err := rec.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Tokens and form bodies are left out of the fixture
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	fixture := string(data)
+	for _, secret := range []string{c.Credentials.AccessToken, c.Credentials.RefreshToken, "refresh-0", "Bearer access"} {
+		if strings.Contains(fixture, secret) {
+			t.Errorf("fixture contains %q", secret)
+		}
+	}
+
+	interactions := rec.Interactions()
+	if len(interactions) != 4 {
+		t.Fatalf("recorded %d interactions, want 4", len(interactions))
+	}
+	login := interactions[0]
+	if login.Endpoint != "/oauth2/token" || login.RequestBody != "" || !strings.Contains(login.Body, "REDACTED") {
+		t.Errorf("login interaction = %+v, want a redacted response and no request body", login)
+	}
+	if order := interactions[3]; order.Method != "POST" || !strings.Contains(order.RequestBody, `"symbolId":8049`) {
+		t.Errorf("order interaction = %+v, want its JSON request body", order)
+	}
+
+	// Replay the session without the server
+	rep, err := qapitest.LoadReplayer(path)
+	if err != nil {
+		t.Fatalf("LoadReplayer: %v", err)
+	}
+	replay, err := qapi.NewClient("", true,
+		qapi.WithHTTPClient(&http.Client{Transport: rep}),
+		qapi.WithLoginURL("http://replay.invalid/oauth2/"),
+		qapi.WithRetryPolicy(qapi.RetryPolicy{}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	positions, err := replay.GetPositions("12345678")
+	if err != nil || len(positions) != 1 || positions[0].Symbol != "AAPL" {
+		t.Errorf("replayed GetPositions = %+v %v, want the AAPL position", positions, err)
+	}
+
+	// Requests are matched by method, endpoint and query
+	if _, err := replay.GetPositions("87654321"); err == nil {
+		t.Error("GetPositions of another account: expected an error")
+	}
+	if _, err := replay.GetCandles(8049, start, end.Add(time.Minute), qapi.IntervalOneMinute); err == nil {
+		t.Error("GetCandles with another range: expected an error")
+	}
+	req, _ := http.NewRequest("GET", "http://replay.invalid/v1/accounts/12345678/orders/", nil)
+	if res, err := rep.RoundTrip(req); err != nil {
+		t.Errorf("RoundTrip: %v", err)
+	} else if res.StatusCode != http.StatusNotFound {
+		t.Errorf("GET of the POSTed orders endpoint = %s, want HTTP 404", res.Status)
+	}
+
+	got, err := replay.GetCandles(8049, start, end, qapi.IntervalOneMinute)
+	if err != nil || len(got) != len(candles) {
+		t.Errorf("replayed GetCandles = %d candles %v, want %d", len(got), err, len(candles))
+	}
+
+	if unused := rep.Unused(); len(unused) != 1 || unused[0].Method != "POST" {
+		t.Errorf("unused interactions = %+v, want only the order", unused)
+	}
+}
@@ -0,0 +1,443 @@
+// Package qapitest provides a fake Questrade API server for testing programs that
+// use qapi, without a Questrade account or a network connection.
+//
+// The server implements the login, account, balance, position, execution, order,
+// symbol, quote, candle and market endpoints against in-memory state that tests
+// set up and inspect with the server's methods. Requests can be made to fail with
+// any status code or Questrade error code by injecting faults.
+//
+//	srv := qapitest.NewServer()
+//	defer srv.Close()
+//
+//	srv.AddAccount(qapi.Account{Number: "12345678", Type: qapi.AccountMargin})
+//	srv.SetQuote(qapi.Quote{SymbolID: 8049, Symbol: "AAPL", BidPrice: qapi.MustParseMoney("150.10")})
+//
+//	client, err := srv.NewClient()
+//	quote, err := client.GetQuote(8049)
+//
+// Clients created some other way can be pointed at the server with the
+// qapi.WithLoginURL option, using the refresh token returned by RefreshToken.
+//
+// The package also provides a Recorder, which captures the requests made to the
+// real API in a fixture file, and a Replayer, which answers requests from that
+// file so that integration tests can run without a network connection.
+package qapitest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// DefaultMaxCandles is the number of candles returned by a single candle request,
+// matching the limit of the Questrade API.
+const DefaultMaxCandles = 2000
+
+// Fault describes requests that the server should fail, and how to fail them.
+type Fault struct {
+	// HTTP method of the requests to fail (e.g., "POST"). Any method matches if empty.
+	Method string
+
+	// Path of the requests to fail, as a pattern for path.Match
+	// (e.g., "/v1/accounts/*/orders/"). Any path matches if empty.
+	Path string
+
+	// HTTP status code of the response. Defaults to 500.
+	StatusCode int
+
+	// Questrade error code and message in the response body.
+	Code    int
+	Message string
+
+	// If set, sent in the Retry-After header of the response.
+	RetryAfter time.Duration
+
+	// Number of matching requests to fail. If zero, requests are failed until the
+	// fault is cleared.
+	Times int
+}
+
+// matches determines whether the fault applies to a request
+func (f Fault) matches(r *http.Request) bool {
+	if f.Method != "" && f.Method != r.Method {
+		return false
+	}
+	if f.Path == "" {
+		return true
+	}
+	ok, _ := path.Match(f.Path, r.URL.Path)
+	return ok
+}
+
+// Request is a request received by the server.
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Body   []byte
+}
+
+// Server is a fake Questrade login and API server. It is safe for concurrent use.
+type Server struct {
+	// URL of the server, without a trailing slash (e.g., "http://127.0.0.1:51234").
+	URL string
+
+	srv *httptest.Server
+	mu  sync.Mutex
+
+	// Session state
+	refreshToken string
+	accessTokens map[string]bool
+	expiresIn    int
+	tokenSeq     int
+
+	// Account state
+	userID     int
+	accounts   []qapi.Account
+	balances   map[string]qapi.AccountBalances
+	positions  map[string][]qapi.Position
+	executions map[string][]qapi.Execution
+	orders     map[string][]qapi.Order
+	orderSeq   int
+
+	// Market state
+	symbols    map[int]qapi.Symbol
+	quotes     map[int]qapi.Quote
+	candles    map[candleKey][]qapi.Candlestick
+	markets    []qapi.Market
+	now        func() time.Time
+	maxCandles int
+
+	faults   []Fault
+	requests []Request
+}
+
+// candleKey identifies a candle series
+type candleKey struct {
+	id       int
+	interval qapi.CandleInterval
+}
+
+// NewServer starts a fake server with no accounts or market data. It must be
+// closed when the test is done with it.
+func NewServer() *Server {
+	s := &Server{
+		refreshToken: "refresh-0",
+		accessTokens: map[string]bool{},
+		expiresIn:    1800,
+		userID:       1,
+		balances:     map[string]qapi.AccountBalances{},
+		positions:    map[string][]qapi.Position{},
+		executions:   map[string][]qapi.Execution{},
+		orders:       map[string][]qapi.Order{},
+		orderSeq:     1000,
+		symbols:      map[int]qapi.Symbol{},
+		quotes:       map[int]qapi.Quote{},
+		candles:      map[candleKey][]qapi.Candlestick{},
+		now:          time.Now,
+		maxCandles:   DefaultMaxCandles,
+	}
+
+	s.srv = httptest.NewServer(s.intercept(http.HandlerFunc(s.dispatch)))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts down the server.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// LoginURL returns the URL of the server's login endpoints, for use with the
+// qapi.WithLoginURL option.
+func (s *Server) LoginURL() string {
+	return s.URL + "/oauth2/"
+}
+
+// NewClient creates a client that logs into the server with its current refresh
+// token. Options are applied after the ones pointing the client at the server.
+func (s *Server) NewClient(opts ...qapi.ClientOption) (*qapi.Client, error) {
+	opts = append([]qapi.ClientOption{qapi.WithLoginURL(s.LoginURL())}, opts...)
+	return qapi.NewClient(s.RefreshToken(), true, opts...)
+}
+
+// RefreshToken returns the refresh token that the server will currently accept.
+// Like Questrade, the server replaces the refresh token on every login.
+func (s *Server) RefreshToken() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.refreshToken
+}
+
+// SetExpiresIn sets the lifetime, in seconds, of the access tokens issued by the
+// server. The server itself never expires tokens - see ExpireSessions.
+func (s *Server) SetExpiresIn(secs int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expiresIn = secs
+}
+
+// ExpireSessions invalidates every access token that has been issued, so that
+// the next API request of each client is rejected with HTTP 401.
+func (s *Server) ExpireSessions() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accessTokens = map[string]bool{}
+}
+
+// SetNow sets the function the server uses to get the current time, which is
+// reported by the time endpoint and used to timestamp orders.
+func (s *Server) SetNow(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SetMaxCandles sets the number of candles returned by a single candle request.
+// Defaults to DefaultMaxCandles.
+func (s *Server) SetMaxCandles(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.maxCandles = n
+}
+
+// SetUserID sets the user ID returned along with the accounts.
+func (s *Server) SetUserID(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.userID = id
+}
+
+// AddAccount adds an account, replacing any existing account with the same number.
+func (s *Server) AddAccount(a qapi.Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for k := range s.accounts {
+		if s.accounts[k].Number == a.Number {
+			s.accounts[k] = a
+			return
+		}
+	}
+	s.accounts = append(s.accounts, a)
+}
+
+// SetBalances sets the balances of an account.
+func (s *Server) SetBalances(number string, b qapi.AccountBalances) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.balances[number] = b
+}
+
+// SetPositions sets the positions of an account.
+func (s *Server) SetPositions(number string, p []qapi.Position) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.positions[number] = append([]qapi.Position(nil), p...)
+}
+
+// AddExecution adds an execution to an account.
+func (s *Server) AddExecution(number string, e qapi.Execution) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.executions[number] = append(s.executions[number], e)
+}
+
+// AddOrder adds an order to an account as-is. If the order has no ID, one is
+// assigned, and the order with its ID is returned.
+func (s *Server) AddOrder(number string, o qapi.Order) qapi.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if o.ID == 0 {
+		s.orderSeq++
+		o.ID = s.orderSeq
+	}
+	if o.ChainID == 0 {
+		o.ChainID = o.ID
+	}
+	s.orders[number] = append(s.orders[number], o)
+	return o
+}
+
+// Orders returns the orders of an account, including orders placed by clients.
+func (s *Server) Orders(number string) []qapi.Order {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]qapi.Order(nil), s.orders[number]...)
+}
+
+// FillOrder executes the remaining quantity of an open order at the given price,
+// and records the execution. Positions and balances are not changed. Returns false
+// if the order doesn't exist or is not open.
+func (s *Server) FillOrder(number string, orderID int, price qapi.Money) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	o := s.findOrder(number, orderID)
+	if o == nil || !isOpen(o.State) {
+		return false
+	}
+
+	now := s.now()
+	qty := o.OpenQuantity
+	o.FilledQuantity += qty
+	o.OpenQuantity = 0
+	o.AvgExecPrice = price
+	o.LastExecPrice = price
+	o.State = qapi.OrderStateExecuted
+	o.UpdateTime = &now
+
+	s.executions[number] = append(s.executions[number], qapi.Execution{
+		Symbol:       o.Symbol,
+		SymbolID:     o.SymbolID,
+		Quantity:     qty,
+		Side:         o.Side,
+		Price:        price,
+		ID:           len(s.executions[number]) + 1,
+		OrderID:      o.ID,
+		OrderChainID: o.ChainID,
+		Timestamp:    now,
+		TotalCost:    price.MulInt(int64(qty)),
+	})
+	return true
+}
+
+// AddSymbol adds a symbol, replacing any existing symbol with the same ID.
+func (s *Server) AddSymbol(sym qapi.Symbol) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols[sym.SymbolID] = sym
+}
+
+// SetQuote sets the quote of a symbol.
+func (s *Server) SetQuote(q qapi.Quote) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotes[q.SymbolID] = q
+}
+
+// SetCandles sets the candles of a symbol at the given interval.
+// Candles must be in chronological order.
+func (s *Server) SetCandles(id int, interval qapi.CandleInterval, candles []qapi.Candlestick) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.candles[candleKey{id, interval}] = append([]qapi.Candlestick(nil), candles...)
+}
+
+// SetMarkets sets the markets returned by the markets endpoint.
+func (s *Server) SetMarkets(m []qapi.Market) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.markets = append([]qapi.Market(nil), m...)
+}
+
+// Inject adds a fault. Faults are checked in the order they were added, and the
+// first one that matches a request is used to fail it.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = append(s.faults, f)
+}
+
+// ClearFaults removes all faults.
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = nil
+}
+
+// Requests returns the requests the server has received, in the order they arrived.
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// fault returns the fault that applies to a request, if any, and uses it up. The
+// caller must hold the lock.
+func (s *Server) fault(r *http.Request) (Fault, bool) {
+	for k, f := range s.faults {
+		if !f.matches(r) {
+			continue
+		}
+
+		if f.Times > 0 {
+			s.faults[k].Times--
+			if s.faults[k].Times == 0 {
+				s.faults = append(s.faults[:k], s.faults[k+1:]...)
+			}
+		}
+		return f, true
+	}
+	return Fault{}, false
+}
+
+// issueToken starts a new session and returns its credentials. The caller must
+// hold the lock.
+func (s *Server) issueToken() qapi.LoginCredentials {
+	s.tokenSeq++
+	access := "access-" + strconv.Itoa(s.tokenSeq)
+	s.refreshToken = "refresh-" + strconv.Itoa(s.tokenSeq)
+	s.accessTokens[access] = true
+
+	return qapi.LoginCredentials{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    s.expiresIn,
+		RefreshToken: s.refreshToken,
+		ApiServer:    s.URL + "/",
+	}
+}
+
+// findOrder returns a pointer to an order of an account. The caller must hold the lock.
+func (s *Server) findOrder(number string, id int) *qapi.Order {
+	for k := range s.orders[number] {
+		if s.orders[number][k].ID == id {
+			return &s.orders[number][k]
+		}
+	}
+	return nil
+}
+
+// hasAccount determines whether an account exists. The caller must hold the lock.
+func (s *Server) hasAccount(number string) bool {
+	for _, a := range s.accounts {
+		if a.Number == number {
+			return true
+		}
+	}
+	return false
+}
+
+// isOpen determines whether an order in the given state can still be filled
+func isOpen(state qapi.OrderState) bool {
+	switch state {
+	case qapi.OrderStatePending, qapi.OrderStateAccepted, qapi.OrderStatePartial,
+		qapi.OrderStateQueued, qapi.OrderStateTriggered, qapi.OrderStateActivated:
+		return true
+	}
+	return false
+}
+
+// writeJSON sends a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError sends a Questrade error response
+func writeError(w http.ResponseWriter, status int, code int, message string) {
+	writeJSON(w, status, struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{code, message})
+}
@@ -0,0 +1,176 @@
+package qapitest_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/qapitest"
+)
+
+// paths returns the method and path of the requests the server received since
+// the given number of requests
+func paths(srv *qapitest.Server, since int) []string {
+	var p []string
+	for _, r := range srv.Requests()[since:] {
+		p = append(p, r.Method+" "+r.Path)
+	}
+	return p
+}
+
+// equal determines whether two lists of strings are the same
+func equal(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k := range a {
+		if a[k] != b[k] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestNewClientLogsIn(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+	initial := srv.RefreshToken()
+
+	c, err := srv.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	if got, want := paths(srv, 0), []string{"POST /oauth2/token"}; !equal(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+	if srv.RefreshToken() == initial {
+		t.Error("refresh token was not replaced by the login")
+	}
+	if c.Credentials.RefreshToken != srv.RefreshToken() {
+		t.Errorf("client refresh token = %q, want %q", c.Credentials.RefreshToken, srv.RefreshToken())
+	}
+	if c.SessionExpiry().Before(time.Now()) {
+		t.Errorf("session expiry %v is in the past", c.SessionExpiry())
+	}
+
+	if _, err := c.GetServerTime(); err != nil {
+		t.Errorf("GetServerTime: %v", err)
+	}
+}
+
+func TestExpiredSessionIsRefreshed(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(qapi.Account{Number: "12345678", Type: qapi.AccountMargin})
+
+	c, err := srv.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	stale := c.Credentials.AccessToken
+
+	srv.ExpireSessions()
+	sent := len(srv.Requests())
+
+	_, accts, err := c.GetAccounts()
+	if err != nil {
+		t.Fatalf("GetAccounts: %v", err)
+	}
+	if len(accts) != 1 || accts[0].Number != "12345678" {
+		t.Errorf("accounts = %+v, want account 12345678", accts)
+	}
+
+	// Rejected with HTTP 401, then retried after logging in again
+	want := []string{"GET /v1/accounts", "POST /oauth2/token", "GET /v1/accounts"}
+	if got := paths(srv, sent); !equal(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+	if c.Credentials.AccessToken == stale {
+		t.Error("access token was not replaced")
+	}
+}
+
+func TestInjectedFaultIsRetried(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+	srv.SetQuote(qapi.Quote{SymbolID: 8049, Symbol: "AAPL", BidPrice: qapi.MustParseMoney("150.10")})
+
+	c, err := srv.NewClient(qapi.WithRetryPolicy(qapi.RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	srv.Inject(qapitest.Fault{Path: "/v1/markets/quotes/*", StatusCode: http.StatusServiceUnavailable, Times: 1})
+	sent := len(srv.Requests())
+
+	q, err := c.GetQuote(8049)
+	if err != nil {
+		t.Fatalf("GetQuote: %v", err)
+	}
+	if q.Symbol != "AAPL" || q.BidPrice.String() != "150.10" {
+		t.Errorf("quote = %s %s, want AAPL 150.10", q.Symbol, q.BidPrice)
+	}
+
+	want := []string{"GET /v1/markets/quotes/8049", "GET /v1/markets/quotes/8049"}
+	if got := paths(srv, sent); !equal(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestPlaceAndDeleteOrder(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(qapi.Account{Number: "12345678", Type: qapi.AccountMargin})
+	srv.AddSymbol(qapi.Symbol{SymbolID: 8049, Symbol: "AAPL"})
+
+	c, err := srv.NewClient()
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	placed, err := c.PlaceOrder(qapi.OrderRequest{
+		AccountID:   "12345678",
+		SymbolID:    8049,
+		Quantity:    10,
+		OrderType:   qapi.OrderTypeLimit,
+		LimitPrice:  qapi.MustParseMoney("150.00"),
+		TimeInForce: qapi.TimeInForceDay,
+		Action:      qapi.SideBuy,
+	})
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+	if len(placed) != 1 || placed[0].Symbol != "AAPL" || placed[0].State != qapi.OrderStateAccepted {
+		t.Fatalf("placed orders = %+v, want one accepted AAPL order", placed)
+	}
+
+	open, err := c.GetOrders("12345678", time.Time{}, time.Time{}, qapi.OrderFilterOpen)
+	if err != nil || len(open) != 1 || open[0].ID != placed[0].ID {
+		t.Errorf("open orders = %+v %v, want order %d", open, err, placed[0].ID)
+	}
+
+	if err := c.DeleteOrder("12345678", placed[0].ID); err != nil {
+		t.Fatalf("DeleteOrder: %v", err)
+	}
+
+	orders := srv.Orders("12345678")
+	if len(orders) != 1 || orders[0].State != qapi.OrderStateCanceled || orders[0].CanceledQuantity != 10 {
+		t.Errorf("orders after DeleteOrder = %+v, want one canceled order", orders)
+	}
+
+	open, err = c.GetOrders("12345678", time.Time{}, time.Time{}, qapi.OrderFilterOpen)
+	if err != nil || len(open) != 0 {
+		t.Errorf("open orders after DeleteOrder = %+v %v, want none", open, err)
+	}
+
+	// A canceled order can't be canceled again
+	if err := c.DeleteOrder("12345678", placed[0].ID); err == nil {
+		t.Error("second DeleteOrder: expected an error")
+	}
+}
@@ -0,0 +1,134 @@
+package qapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitCategory identifies which of Questrade's rate limit buckets an API call
+// counts against. Account calls and market data calls are limited separately.
+//
+// Ref: http://www.questrade.com/api/documentation/rate-limiting
+type RateLimitCategory int
+
+const (
+	// AccountCalls covers the account, order and server time endpoints.
+	AccountCalls RateLimitCategory = iota
+
+	// MarketDataCalls covers the symbol and market endpoints.
+	MarketDataCalls
+)
+
+// String returns the name of the rate limit category
+func (r RateLimitCategory) String() string {
+	if r == MarketDataCalls {
+		return "MarketDataCalls"
+	}
+	return "AccountCalls"
+}
+
+// rateLimitCategory determines the rate limit category of an API endpoint
+func rateLimitCategory(endpoint string) RateLimitCategory {
+	if strings.HasPrefix(endpoint, "v1/markets") || strings.HasPrefix(endpoint, "v1/symbols") {
+		return MarketDataCalls
+	}
+	return AccountCalls
+}
+
+// RateLimit is the remaining request budget for a category of API calls, as
+// last reported by the Questrade server.
+type RateLimit struct {
+	// Number of requests remaining before the budget is exhausted.
+	Remaining int
+
+	// Time at which the budget will be replenished.
+	Reset time.Time
+
+	// Whether the server has reported the budget yet. If false, the other
+	// fields are zero-value and requests are not being limited.
+	Known bool
+}
+
+// rateLimiter tracks the request budget of each rate limit category, and blocks
+// requests when a budget is exhausted until it is reset.
+type rateLimiter struct {
+	mu     sync.Mutex
+	limits [2]RateLimit
+}
+
+// budget returns the current budget for a category
+func (l *rateLimiter) budget(cat RateLimitCategory) RateLimit {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.current(cat)
+}
+
+// current returns the budget for a category. The caller must hold the lock.
+func (l *rateLimiter) current(cat RateLimitCategory) RateLimit {
+	// A budget that has passed its reset time is no longer accurate
+	if l.limits[cat].Known && !time.Now().Before(l.limits[cat].Reset) {
+		l.limits[cat] = RateLimit{}
+	}
+	return l.limits[cat]
+}
+
+// wait blocks until a request in the given category can be made, or until the
+// context is done. The request is counted against the budget.
+func (l *rateLimiter) wait(ctx context.Context, cat RateLimitCategory) error {
+	for {
+		l.mu.Lock()
+		limit := l.current(cat)
+		if !limit.Known || limit.Remaining > 0 {
+			if limit.Known {
+				l.limits[cat].Remaining--
+			}
+			l.mu.Unlock()
+			return nil
+		}
+		l.mu.Unlock()
+
+		timer := time.NewTimer(limit.Reset.Sub(time.Now()))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// update records the budget reported in the headers of an API response. A rate
+// limited response without headers is treated as an exhausted budget.
+func (l *rateLimiter) update(cat RateLimitCategory, res *http.Response) {
+	remaining, err := strconv.Atoi(res.Header.Get("X-RateLimit-Remaining"))
+	if err != nil {
+		if res.StatusCode != http.StatusTooManyRequests {
+			return
+		}
+		remaining = 0
+	}
+
+	reset, err := strconv.ParseInt(res.Header.Get("X-RateLimit-Reset"), 10, 64)
+	resetTime := time.Unix(reset, 0)
+	if err != nil {
+		resetTime = time.Now().Add(time.Second)
+	}
+
+	l.mu.Lock()
+	l.limits[cat] = RateLimit{
+		Remaining: remaining,
+		Reset:     resetTime,
+		Known:     true,
+	}
+	l.mu.Unlock()
+}
+
+// RateLimit returns the remaining request budget for a category of API calls.
+// Requests made by the client wait for the budget to reset once it is exhausted.
+func (c *Client) RateLimit(cat RateLimitCategory) RateLimit {
+	return c.limiter.budget(cat)
+}
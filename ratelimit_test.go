@@ -0,0 +1,186 @@
+package qapi_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// rateLimitServer is an API server that answers the server time and quote
+// endpoints, and lets each test choose the rate limit headers of the responses.
+type rateLimitServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests int
+	headers  func(n int, h http.Header) int // sets the headers of the nth request, and returns the status code
+}
+
+func newRateLimitServer(headers func(n int, h http.Header) int) *rateLimitServer {
+	s := &rateLimitServer{headers: headers}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		status := s.headers(s.requests, w.Header())
+		s.mu.Unlock()
+
+		w.WriteHeader(status)
+		switch {
+		case status != http.StatusOK:
+			fmt.Fprint(w, `{"code":1006,"message":"Rate limit exceeded"}`)
+		case r.URL.Path == "/v1/time":
+			fmt.Fprint(w, `{"time":"2020-01-06T09:30:00-05:00"}`)
+		default:
+			fmt.Fprint(w, `{"quotes":[{"symbol":"AAPL","symbolId":8049}]}`)
+		}
+	}))
+	return s
+}
+
+// Requests returns the number of requests the server has received
+func (s *rateLimitServer) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// newRateLimitClient creates a client with a session on the server that doesn't
+// retry failed requests
+func newRateLimitClient(t *testing.T, srv *rateLimitServer) *qapi.Client {
+	c, err := qapi.NewClient("", true,
+		qapi.WithCredentials(qapi.LoginCredentials{AccessToken: "access", TokenType: "Bearer", ApiServer: srv.URL + "/"}),
+		qapi.WithRetryPolicy(qapi.RetryPolicy{}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+func TestRateLimitFromHeaders(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+	srv := newRateLimitServer(func(n int, h http.Header) int {
+		h.Set("X-RateLimit-Remaining", fmt.Sprint(30-n))
+		h.Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		return http.StatusOK
+	})
+	defer srv.Close()
+	c := newRateLimitClient(t, srv)
+
+	if limit := c.RateLimit(qapi.AccountCalls); limit.Known {
+		t.Errorf("RateLimit before any request = %+v, want an unknown budget", limit)
+	}
+
+	if _, err := c.GetServerTime(); err != nil {
+		t.Fatalf("GetServerTime: %v", err)
+	}
+	if limit := c.RateLimit(qapi.AccountCalls); !limit.Known || limit.Remaining != 29 || !limit.Reset.Equal(reset) {
+		t.Errorf("AccountCalls = %+v, want 29 remaining until %v", limit, reset)
+	}
+
+	// Each category has its own budget
+	if limit := c.RateLimit(qapi.MarketDataCalls); limit.Known {
+		t.Errorf("MarketDataCalls = %+v, want an unknown budget", limit)
+	}
+	if _, err := c.GetQuote(8049); err != nil {
+		t.Fatalf("GetQuote: %v", err)
+	}
+	if limit := c.RateLimit(qapi.MarketDataCalls); limit.Remaining != 28 {
+		t.Errorf("MarketDataCalls = %+v, want 28 remaining", limit)
+	}
+	if limit := c.RateLimit(qapi.AccountCalls); limit.Remaining != 29 {
+		t.Errorf("AccountCalls = %+v, want 29 remaining", limit)
+	}
+}
+
+func TestRateLimitCountsRequestsBetweenUpdates(t *testing.T) {
+	reset := time.Now().Add(time.Hour)
+	srv := newRateLimitServer(func(n int, h http.Header) int {
+		// Only the first response reports the budget
+		if n == 1 {
+			h.Set("X-RateLimit-Remaining", "1")
+			h.Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		}
+		return http.StatusOK
+	})
+	defer srv.Close()
+	c := newRateLimitClient(t, srv)
+
+	for k := 0; k < 2; k++ {
+		if _, err := c.GetServerTime(); err != nil {
+			t.Fatalf("GetServerTime: %v", err)
+		}
+	}
+	if limit := c.RateLimit(qapi.AccountCalls); limit.Remaining != 0 {
+		t.Errorf("AccountCalls = %+v, want 0 remaining", limit)
+	}
+
+	// The budget is spent, so the next request waits for a reset that is an
+	// hour away, and isn't sent
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetServerTimeContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetServerTimeContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := srv.Requests(); n != 2 {
+		t.Errorf("server received %d requests, want 2", n)
+	}
+}
+
+func TestRateLimitWaitsForReset(t *testing.T) {
+	// The reset time has a resolution of a second, so it is at least a second
+	// away
+	reset := time.Now().Add(2 * time.Second).Truncate(time.Second)
+	srv := newRateLimitServer(func(n int, h http.Header) int {
+		h.Set("X-RateLimit-Remaining", "0")
+		h.Set("X-RateLimit-Reset", fmt.Sprint(reset.Unix()))
+		return http.StatusOK
+	})
+	defer srv.Close()
+	c := newRateLimitClient(t, srv)
+
+	if _, err := c.GetServerTime(); err != nil {
+		t.Fatalf("GetServerTime: %v", err)
+	}
+	if _, err := c.GetServerTime(); err != nil {
+		t.Fatalf("GetServerTime: %v", err)
+	}
+	if now := time.Now(); now.Before(reset) {
+		t.Errorf("second request sent at %v, before the reset at %v", now, reset)
+	}
+}
+
+func TestRateLimitedResponseWithoutHeaders(t *testing.T) {
+	srv := newRateLimitServer(func(n int, h http.Header) int {
+		if n == 1 {
+			return http.StatusTooManyRequests
+		}
+		return http.StatusOK
+	})
+	defer srv.Close()
+	c := newRateLimitClient(t, srv)
+
+	if _, err := c.GetQuote(8049); err == nil {
+		t.Fatal("GetQuote: expected an error")
+	}
+
+	// The budget is treated as spent for a second
+	limit := c.RateLimit(qapi.MarketDataCalls)
+	if !limit.Known || limit.Remaining != 0 || limit.Reset.After(time.Now().Add(time.Second)) {
+		t.Errorf("MarketDataCalls = %+v, want 0 remaining for up to a second", limit)
+	}
+
+	start := time.Now()
+	if _, err := c.GetQuote(8049); err != nil {
+		t.Fatalf("GetQuote: %v", err)
+	}
+	if waited := time.Since(start); waited < 500*time.Millisecond {
+		t.Errorf("GetQuote waited %v after HTTP 429, want about a second", waited)
+	}
+}
@@ -0,0 +1,163 @@
+package qapi
+
+import (
+	"errors"
+	"time"
+)
+
+// Resample combines candles into candles of a longer period, such as 7-minute
+// candles from one-minute candles. The candles must be sorted by start time, and
+// the period should be a multiple of their length.
+//
+// Periods shorter than a day are aligned to the opening of each trading session,
+// and never span the open or close: the last candle of a session ends at the
+// close. Extended hours candles are grouped separately, aligned to the close.
+//
+// Periods of a day or longer must be a whole number of days. A period of a week,
+// or a number of weeks, combines calendar weeks from Monday to Sunday. Other
+// periods combine that many trading days. Their candles start at the first candle
+// they combine, and end at the end of the last.
+func (h MarketHours) Resample(candles []Candlestick, period time.Duration) ([]Candlestick, error) {
+	const oneDay = 24 * time.Hour
+	const oneWeek = 7 * oneDay
+
+	switch {
+	case period <= 0:
+		return []Candlestick{}, errors.New("Error: Resampling period must be positive")
+	case period < oneDay:
+		return h.resampleIntraday(candles, period), nil
+	case period%oneDay != 0:
+		return []Candlestick{}, errors.New("Error: Resampling periods of a day or longer must be whole days")
+	case period%oneWeek == 0:
+		return h.resampleCalendar(candles, int(period/oneWeek), h.weekOf), nil
+	default:
+		return h.resampleCalendar(candles, int(period/oneDay), h.dateOf), nil
+	}
+}
+
+// ResampleInterval combines candles into candles of one of the API's intervals,
+// such as weekly candles from daily candles. OneMonth and OneYear combine calendar
+// months and years - other intervals are resampled as by Resample.
+func (h MarketHours) ResampleInterval(candles []Candlestick, interval CandleInterval) ([]Candlestick, error) {
+	switch interval {
+	case IntervalOneMonth:
+		return h.resampleCalendar(candles, 1, func(t time.Time) time.Time {
+			y, m, _ := t.In(h.location()).Date()
+			return time.Date(y, m, 1, 0, 0, 0, 0, h.location())
+		}), nil
+	case IntervalOneYear:
+		return h.resampleCalendar(candles, 1, func(t time.Time) time.Time {
+			return time.Date(t.In(h.location()).Year(), 1, 1, 0, 0, 0, 0, h.location())
+		}), nil
+	}
+
+	if !interval.Valid() {
+		return []Candlestick{}, errors.New("Error: Unknown candle interval " + string(interval))
+	}
+	return h.Resample(candles, interval.Duration())
+}
+
+// resampleIntraday combines candles into periods aligned to the session open
+func (h MarketHours) resampleIntraday(candles []Candlestick, period time.Duration) []Candlestick {
+	out := []Candlestick{}
+	var bucketStart time.Time
+
+	for _, c := range candles {
+		start, end := h.bucket(c.Start, period)
+		if len(out) > 0 && start.Equal(bucketStart) {
+			last := combine(out[len(out)-1], c)
+			last.End = end
+			out[len(out)-1] = last
+			continue
+		}
+
+		bucketStart = start
+		c.Start, c.End = start, end
+		out = append(out, c)
+	}
+	return out
+}
+
+// bucket returns the period that a time falls into. Periods are aligned to the
+// open during the session and before it, and to the close after it, and are cut
+// short so that they don't span the open or close.
+func (h MarketHours) bucket(t time.Time, period time.Duration) (time.Time, time.Time) {
+	open, close := h.session(t)
+
+	anchor, limit := open, open
+	switch {
+	case !t.Before(close):
+		anchor, limit = close, day(t, h.location()).AddDate(0, 0, 1)
+	case !t.Before(open):
+		limit = close
+	}
+
+	offset := t.Sub(anchor)
+	n := offset / period
+	if offset < 0 && offset%period != 0 {
+		n--
+	}
+
+	start := anchor.Add(n * period)
+	end := start.Add(period)
+	if end.After(limit) && start.Before(limit) {
+		end = limit
+	}
+	return start, end
+}
+
+// resampleCalendar combines candles into groups of n calendar units, such as
+// days or weeks. unit returns the start of the unit that a time falls into.
+func (h MarketHours) resampleCalendar(candles []Candlestick, n int, unit func(time.Time) time.Time) []Candlestick {
+	out := []Candlestick{}
+	var lastUnit time.Time
+	units := 0
+
+	for _, c := range candles {
+		u := unit(c.Start)
+		if len(out) > 0 && u.Equal(lastUnit) {
+			out[len(out)-1] = combine(out[len(out)-1], c)
+			continue
+		}
+
+		lastUnit = u
+		if len(out) > 0 && units < n {
+			units++
+			out[len(out)-1] = combine(out[len(out)-1], c)
+			continue
+		}
+
+		units = 1
+		out = append(out, c)
+	}
+	return out
+}
+
+// dateOf returns midnight on the date of a time, in the market's time zone
+func (h MarketHours) dateOf(t time.Time) time.Time {
+	return day(t, h.location())
+}
+
+// weekOf returns midnight on the Monday of the week of a time, in the market's
+// time zone
+func (h MarketHours) weekOf(t time.Time) time.Time {
+	d := day(t, h.location())
+	offset := (int(d.Weekday()) + 6) % 7
+	return d.AddDate(0, 0, -offset)
+}
+
+// combine adds a candle to the end of another
+func combine(a Candlestick, b Candlestick) Candlestick {
+	if b.High.Cmp(a.High) > 0 {
+		a.High = b.High
+	}
+	if b.Low.Cmp(a.Low) < 0 {
+		a.Low = b.Low
+	}
+	a.Close = b.Close
+	a.Volume += b.Volume
+	if b.End.After(a.End) {
+		a.End = b.End
+	}
+	return a
+}
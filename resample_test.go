@@ -0,0 +1,243 @@
+package qapi_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+var (
+	est = time.FixedZone("EST", -5*60*60)
+
+	// Regular hours of the Toronto and New York stock exchanges in the winter
+	nyse = qapi.MarketHours{Location: est, Open: 9*time.Hour + 30*time.Minute, Close: 16 * time.Hour}
+)
+
+// minutes returns one-minute candles from a time on January 6, 2020. The kth
+// candle opens and closes at 10 + k cents, five cents either side of its high
+// and low, with a volume of 100.
+func minutes(hour int, min int, n int) []qapi.Candlestick {
+	start := time.Date(2020, 1, 6, hour, min, 0, 0, est)
+	candles := []qapi.Candlestick{}
+	for k := 0; k < n; k++ {
+		price := qapi.MoneyFromInt(10).Add(qapi.MustParseMoney("0.01").MulInt(int64(k)))
+		candles = append(candles, qapi.Candlestick{
+			Start:  start.Add(time.Duration(k) * time.Minute),
+			End:    start.Add(time.Duration(k+1) * time.Minute),
+			Open:   price,
+			High:   price.Add(qapi.MustParseMoney("0.05")),
+			Low:    price.Sub(qapi.MustParseMoney("0.05")),
+			Close:  price,
+			Volume: 100,
+		})
+	}
+	return candles
+}
+
+// days returns daily candles on the dates, stamped from midnight to the following
+// midnight like the Questrade API stamps them, with a volume of 1000
+func days(dates ...string) []qapi.Candlestick {
+	candles := []qapi.Candlestick{}
+	for k, d := range dates {
+		start, err := time.ParseInLocation("2006-01-02", d, est)
+		if err != nil {
+			panic(err)
+		}
+		price := qapi.MoneyFromInt(int64(20 + k))
+		candles = append(candles, qapi.Candlestick{
+			Start:  start,
+			End:    start.AddDate(0, 0, 1),
+			Open:   price,
+			High:   price.Add(qapi.MoneyFromInt(1)),
+			Low:    price.Sub(qapi.MoneyFromInt(1)),
+			Close:  price,
+			Volume: 1000,
+		})
+	}
+	return candles
+}
+
+// join concatenates lists of candles
+func join(lists ...[]qapi.Candlestick) []qapi.Candlestick {
+	all := []qapi.Candlestick{}
+	for _, l := range lists {
+		all = append(all, l...)
+	}
+	return all
+}
+
+// describe formats candles for comparison
+func describe(candles []qapi.Candlestick) []string {
+	s := []string{}
+	for _, c := range candles {
+		s = append(s, fmt.Sprintf("%s-%s %s/%s/%s/%s %d",
+			c.Start.In(est).Format("Jan 2 15:04"), c.End.In(est).Format("Jan 2 15:04"),
+			c.Open, c.High, c.Low, c.Close, c.Volume))
+	}
+	return s
+}
+
+func TestResampleIntraday(t *testing.T) {
+	tests := []struct {
+		name    string
+		candles []qapi.Candlestick
+		period  time.Duration
+		want    []string
+	}{
+		{"aligned to the open, with a partial trailing bucket", minutes(9, 30, 15), 7 * time.Minute, []string{
+			"Jan 6 09:30-Jan 6 09:37 10.00/10.11/9.95/10.06 700",
+			"Jan 6 09:37-Jan 6 09:44 10.07/10.18/10.02/10.13 700",
+			"Jan 6 09:44-Jan 6 09:51 10.14/10.19/10.09/10.14 100",
+		}},
+		{"first candle inside a bucket", minutes(9, 33, 6), 5 * time.Minute, []string{
+			"Jan 6 09:30-Jan 6 09:35 10.00/10.06/9.95/10.01 200",
+			"Jan 6 09:35-Jan 6 09:40 10.02/10.10/9.97/10.05 400",
+		}},
+		{"cut short at the close", minutes(15, 50, 10), 7 * time.Minute, []string{
+			"Jan 6 15:48-Jan 6 15:55 10.00/10.09/9.95/10.04 500",
+			"Jan 6 15:55-Jan 6 16:00 10.05/10.14/10.00/10.09 500",
+		}},
+		{"extended hours after the close are aligned to it", minutes(16, 0, 10), 7 * time.Minute, []string{
+			"Jan 6 16:00-Jan 6 16:07 10.00/10.11/9.95/10.06 700",
+			"Jan 6 16:07-Jan 6 16:14 10.07/10.14/10.02/10.09 300",
+		}},
+		{"pre-market candles don't span the open", minutes(9, 20, 15), 7 * time.Minute, []string{
+			"Jan 6 09:16-Jan 6 09:23 10.00/10.07/9.95/10.02 300",
+			"Jan 6 09:23-Jan 6 09:30 10.03/10.14/9.98/10.09 700",
+			"Jan 6 09:30-Jan 6 09:37 10.10/10.19/10.05/10.14 500",
+		}},
+		{"sessions are never combined", join(minutes(15, 58, 2), minutes(33, 30, 2)), time.Hour, []string{
+			"Jan 6 15:30-Jan 6 16:00 10.00/10.06/9.95/10.01 200",
+			"Jan 7 09:30-Jan 7 10:30 10.00/10.06/9.95/10.01 200",
+		}},
+		{"empty input", []qapi.Candlestick{}, 5 * time.Minute, []string{}},
+	}
+
+	for _, tt := range tests {
+		got, err := nyse.Resample(tt.candles, tt.period)
+		if err != nil {
+			t.Errorf("%s: Resample: %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprintf("%q", describe(got)) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("%s: Resample =\n%s\nwant\n%s", tt.name, describe(got), tt.want)
+		}
+	}
+}
+
+func TestResampleCombinesPrices(t *testing.T) {
+	start := time.Date(2020, 1, 6, 10, 0, 0, 0, est)
+	candle := func(min int, open, high, low, close string, volume int) qapi.Candlestick {
+		return qapi.Candlestick{
+			Start: start.Add(time.Duration(min) * time.Minute), End: start.Add(time.Duration(min+1) * time.Minute),
+			Open: qapi.MustParseMoney(open), High: qapi.MustParseMoney(high), Low: qapi.MustParseMoney(low),
+			Close: qapi.MustParseMoney(close), Volume: volume,
+		}
+	}
+
+	// The high and low come from the middle candle
+	got, err := nyse.Resample([]qapi.Candlestick{
+		candle(0, "10", "10.10", "9.90", "10.05", 300),
+		candle(1, "10.05", "10.50", "9.50", "10", 500),
+		candle(2, "10", "10.20", "9.80", "10.15", 200),
+	}, 3*time.Minute)
+	want := []string{"Jan 6 10:00-Jan 6 10:03 10.00/10.50/9.50/10.15 1000"}
+	if err != nil || fmt.Sprint(describe(got)) != fmt.Sprint(want) {
+		t.Errorf("Resample = %v %v, want %v", describe(got), err, want)
+	}
+}
+
+func TestResampleDays(t *testing.T) {
+	// January 1 is a holiday, and the 4th and 5th are a weekend
+	jan := days("2020-01-02", "2020-01-03", "2020-01-06", "2020-01-07", "2020-01-08", "2020-01-09", "2020-01-10", "2020-01-13")
+
+	tests := []struct {
+		name    string
+		candles []qapi.Candlestick
+		period  time.Duration
+		want    []string
+	}{
+		{"one day", jan[:2], 24 * time.Hour, []string{
+			"Jan 2 00:00-Jan 3 00:00 20.00/21.00/19.00/20.00 1000",
+			"Jan 3 00:00-Jan 4 00:00 21.00/22.00/20.00/21.00 1000",
+		}},
+		{"three trading days, with a partial trailing bucket", jan, 3 * 24 * time.Hour, []string{
+			"Jan 2 00:00-Jan 7 00:00 20.00/23.00/19.00/22.00 3000",
+			"Jan 7 00:00-Jan 10 00:00 23.00/26.00/22.00/25.00 3000",
+			"Jan 10 00:00-Jan 14 00:00 26.00/28.00/25.00/27.00 2000",
+		}},
+		{"calendar weeks from Monday, with partial weeks at both ends", jan, 7 * 24 * time.Hour, []string{
+			"Jan 2 00:00-Jan 4 00:00 20.00/22.00/19.00/21.00 2000",
+			"Jan 6 00:00-Jan 11 00:00 22.00/27.00/21.00/26.00 5000",
+			"Jan 13 00:00-Jan 14 00:00 27.00/28.00/26.00/27.00 1000",
+		}},
+		{"two calendar weeks", jan, 14 * 24 * time.Hour, []string{
+			"Jan 2 00:00-Jan 11 00:00 20.00/27.00/19.00/26.00 7000",
+			"Jan 13 00:00-Jan 14 00:00 27.00/28.00/26.00/27.00 1000",
+		}},
+		{"empty input", nil, 7 * 24 * time.Hour, []string{}},
+	}
+
+	for _, tt := range tests {
+		got, err := nyse.Resample(tt.candles, tt.period)
+		if err != nil {
+			t.Errorf("%s: Resample: %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprintf("%q", describe(got)) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("%s: Resample =\n%s\nwant\n%s", tt.name, describe(got), tt.want)
+		}
+	}
+}
+
+func TestResampleInterval(t *testing.T) {
+	tests := []struct {
+		name     string
+		candles  []qapi.Candlestick
+		interval qapi.CandleInterval
+		want     []string
+	}{
+		{"calendar months", days("2020-01-30", "2020-01-31", "2020-02-03", "2020-02-04", "2020-03-02"), qapi.IntervalOneMonth, []string{
+			"Jan 30 00:00-Feb 1 00:00 20.00/22.00/19.00/21.00 2000",
+			"Feb 3 00:00-Feb 5 00:00 22.00/24.00/21.00/23.00 2000",
+			"Mar 2 00:00-Mar 3 00:00 24.00/25.00/23.00/24.00 1000",
+		}},
+		{"calendar years", days("2019-12-30", "2019-12-31", "2020-01-02"), qapi.IntervalOneYear, []string{
+			"Dec 30 00:00-Jan 1 00:00 20.00/22.00/19.00/21.00 2000",
+			"Jan 2 00:00-Jan 3 00:00 22.00/23.00/21.00/22.00 1000",
+		}},
+		{"weeks", days("2020-01-10", "2020-01-13", "2020-01-14"), qapi.IntervalOneWeek, []string{
+			"Jan 10 00:00-Jan 11 00:00 20.00/21.00/19.00/20.00 1000",
+			"Jan 13 00:00-Jan 15 00:00 21.00/23.00/20.00/22.00 2000",
+		}},
+		{"minutes", minutes(9, 30, 4), qapi.IntervalTwoMinutes, []string{
+			"Jan 6 09:30-Jan 6 09:32 10.00/10.06/9.95/10.01 200",
+			"Jan 6 09:32-Jan 6 09:34 10.02/10.08/9.97/10.03 200",
+		}},
+		{"empty months", []qapi.Candlestick{}, qapi.IntervalOneMonth, []string{}},
+	}
+
+	for _, tt := range tests {
+		got, err := nyse.ResampleInterval(tt.candles, tt.interval)
+		if err != nil {
+			t.Errorf("%s: ResampleInterval: %v", tt.name, err)
+			continue
+		}
+		if fmt.Sprintf("%q", describe(got)) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("%s: ResampleInterval =\n%s\nwant\n%s", tt.name, describe(got), tt.want)
+		}
+	}
+}
+
+func TestResampleErrors(t *testing.T) {
+	for _, period := range []time.Duration{0, -time.Minute, 36 * time.Hour} {
+		if _, err := nyse.Resample(minutes(9, 30, 2), period); err == nil {
+			t.Errorf("Resample with a period of %v: expected an error", period)
+		}
+	}
+	if _, err := nyse.ResampleInterval(minutes(9, 30, 2), "Fortnight"); err == nil {
+		t.Error("ResampleInterval with an unknown interval: expected an error")
+	}
+}
@@ -0,0 +1,163 @@
+package qapi
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// orderClockSkew is how far before the first attempt at placing an order, and
+// after the current time, the client searches for orders that may have been
+// created by that attempt, in case the server's clock differs from ours.
+const orderClockSkew = time.Minute
+
+// RetryPolicy controls how the client retries requests that fail because of a
+// network error, a server error (HTTP 5xx) or rate limiting (HTTP 429).
+// GET requests are always retried according to the policy, while orders are
+// only retried if RetryOrders is set.
+type RetryPolicy struct {
+	// Maximum number of attempts at a request, including the first. Values
+	// below 2 disable retries.
+	MaxAttempts int
+
+	// Delay before the first retry. The delay doubles on every subsequent retry,
+	// and a random amount of jitter is applied to it.
+	MinBackoff time.Duration
+
+	// Upper limit of the delay between retries.
+	MaxBackoff time.Duration
+
+	// Whether to retry PlaceOrder requests. Before an order is resubmitted, the
+	// account's recent orders are searched for one matching the request, in case
+	// the failed attempt reached the server. If a match is found it is returned
+	// instead of placing the order again, and if the search fails the order is
+	// not retried. Identical orders that existed before the first attempt are
+	// listed beforehand so that they aren't mistaken for it - if they can't be
+	// listed, the order is only sent once.
+	RetryOrders bool
+}
+
+// DefaultRetryPolicy is the retry policy used by new clients.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  500 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+}
+
+// backoff returns the delay before the given retry, which is at least as long as
+// the server asked the client to wait.
+func (p RetryPolicy) backoff(retry int, err error) time.Duration {
+	d := p.MinBackoff
+	for i := 1; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	// Use half of the delay, plus a random portion of the other half
+	if d > 1 {
+		d = d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+	}
+
+	if qe, ok := err.(QuestradeError); ok && qe.RetryAfter > d {
+		d = qe.RetryAfter
+	}
+	return d
+}
+
+// isTransient determines whether a request that failed with the given error
+// is worth retrying.
+func isTransient(err error) bool {
+	switch e := err.(type) {
+	case QuestradeError:
+		return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+	case *url.Error:
+		return e.Err != context.Canceled && e.Err != context.DeadlineExceeded
+	}
+	return false
+}
+
+// isRateLimited determines whether a request was rejected by the server without
+// being processed because the rate limit was exceeded.
+func isRateLimited(err error) bool {
+	qe, ok := err.(QuestradeError)
+	return ok && qe.StatusCode == http.StatusTooManyRequests
+}
+
+// retry calls attempt until it succeeds, it fails with an error that is not
+// transient, the policy's attempts are used up, or the context is done. Each
+// attempt is passed the error from the previous attempt, which is nil the first
+// time. If the context is done while waiting to retry, its error is returned.
+func (c *Client) retry(ctx context.Context, policy RetryPolicy, attempt func(prev error) error) error {
+	var err error
+	for n := 1; ; n++ {
+		err = attempt(err)
+		if err == nil || n >= policy.MaxAttempts || !isTransient(err) {
+			return err
+		}
+
+		timer := time.NewTimer(policy.backoff(n, err))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// matchingOrders returns the orders created since the given time that match the
+// order request. A replacement request is matched against the order chain of the
+// order being replaced.
+func (c *Client) matchingOrders(ctx context.Context, req OrderRequest, since time.Time) ([]Order, error) {
+	chainID := 0
+	if req.OrderID != 0 {
+		orig, err := c.GetOrdersByIDContext(ctx, req.AccountID, req.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		if len(orig) == 1 {
+			chainID = orig[0].ChainID
+		}
+	}
+
+	orders, err := c.GetOrdersContext(ctx, req.AccountID, since, time.Now().Add(orderClockSkew), OrderFilterAll)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []Order{}
+	for _, o := range orders {
+		if o.ID == req.OrderID || (chainID != 0 && o.ChainID != chainID) {
+			continue
+		}
+
+		if o.SymbolID == req.SymbolID && o.TotalQuantity == req.Quantity &&
+			o.Side == req.Action && o.OrderType == req.OrderType &&
+			o.TimeInForce == req.TimeInForce &&
+			o.LimitPrice == req.LimitPrice && o.StopPrice == req.StopPrice {
+			matches = append(matches, o)
+		}
+	}
+	return matches, nil
+}
+
+// findSubmittedOrder searches for an order that was created by an earlier attempt
+// at placing the order request. Orders in existing were created before the first
+// attempt, so they are not considered.
+func (c *Client) findSubmittedOrder(ctx context.Context, req OrderRequest, since time.Time, existing map[int]bool) ([]Order, error) {
+	orders, err := c.matchingOrders(ctx, req, since)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, o := range orders {
+		if !existing[o.ID] {
+			return []Order{o}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,81 @@
+package qapi_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/qapitest"
+)
+
+func TestPlaceOrderRetryIgnoresEarlierIdenticalOrder(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+	srv.AddAccount(qapi.Account{Number: "12345678", Type: qapi.AccountMargin})
+
+	c, err := srv.NewClient(qapi.WithRetryPolicy(qapi.RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+		RetryOrders: true,
+	}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	req := qapi.OrderRequest{
+		AccountID:   "12345678",
+		SymbolID:    8049,
+		Quantity:    10,
+		OrderType:   qapi.OrderTypeLimit,
+		LimitPrice:  qapi.MustParseMoney("150.00"),
+		TimeInForce: qapi.TimeInForceDay,
+		Action:      qapi.SideBuy,
+	}
+
+	// The first order was placed on purpose a few seconds earlier
+	srv.SetNow(func() time.Time { return time.Now().Add(-10 * time.Second) })
+	first, err := c.PlaceOrder(req)
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+
+	// The second order fails without reaching the server, so it must be sent again
+	srv.SetNow(time.Now)
+	srv.Inject(qapitest.Fault{Method: "POST", Path: "/v1/accounts/*/orders/", StatusCode: 503, Times: 1})
+	second, err := c.PlaceOrder(req)
+	if err != nil {
+		t.Fatalf("PlaceOrder: %v", err)
+	}
+
+	if len(second) != 1 || second[0].ID == first[0].ID {
+		t.Errorf("retried order = %+v, want a new order", second)
+	}
+	if n := len(srv.Orders("12345678")); n != 2 {
+		t.Errorf("server has %d orders, want 2", n)
+	}
+}
+
+func TestRetryReturnsContextErrorDuringBackoff(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+
+	c, err := srv.NewClient(qapi.WithRetryPolicy(qapi.RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Minute,
+		MaxBackoff:  time.Minute,
+	}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	srv.Inject(qapitest.Fault{Path: "/v1/time", StatusCode: 500})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.GetServerTimeContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GetServerTimeContext = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -0,0 +1,420 @@
+package sim
+
+import (
+	"sort"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// bar is the range of prices that an order can be filled at, in the order they
+// were traded. A quote is a bar with a single price.
+type bar struct {
+	open, high, low, close qapi.Money
+}
+
+// flatBar returns a bar with a single price
+func flatBar(price qapi.Money) bar {
+	return bar{price, price, price, price}
+}
+
+// bars holds the prices available to buy orders and sell orders
+type bars struct {
+	buy, sell bar
+}
+
+// quoteBars returns the prices of a quote - buy orders are filled at the ask
+// price and sell orders at the bid price
+func quoteBars(q qapi.Quote) bars {
+	ask, bid := q.AskPrice, q.BidPrice
+	if ask.IsZero() {
+		ask = q.LastTradePrice
+	}
+	if bid.IsZero() {
+		bid = q.LastTradePrice
+	}
+	return bars{flatBar(ask), flatBar(bid)}
+}
+
+// Quote gives the simulator a quote for a symbol, received at the given time.
+// Open orders on the symbol are filled if the quote allows it, with buy orders
+// filled at the ask price and sell orders at the bid price. Positions in the
+// symbol are valued at the last trade price. Returns the executions.
+func (s *Simulator) Quote(q qapi.Quote, at time.Time) []qapi.Execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if q.Symbol != "" {
+		s.symbols[q.SymbolID] = q.Symbol
+	} else {
+		q.Symbol = s.symbols[q.SymbolID]
+	}
+	s.quotes[q.SymbolID] = q
+	delete(s.candles, q.SymbolID)
+
+	mark := q.LastTradePrice
+	if mark.IsZero() {
+		mark = q.BidPrice.Add(q.AskPrice).DivInt(2)
+	}
+	return s.update(q.SymbolID, quoteBars(q), mark, at, at)
+}
+
+// Candle gives the simulator a candlestick of a symbol. Open orders on the symbol
+// are filled if the range of the candlestick allows it, as if its prices were
+// traded in the order open, high, low, close: market orders are filled at the
+// open, and limit and stop orders at their price, or at the open if the market
+// gapped through it. Positions in the symbol are valued at the close. Returns
+// the executions, which are timestamped with the end of the candlestick.
+func (s *Simulator) Candle(id int, c qapi.Candlestick) []qapi.Execution {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Orders placed after this candle must wait for the next one, rather than
+	// being filled at its close
+	s.candles[id] = c
+	delete(s.quotes, id)
+
+	b := bar{c.Open, c.High, c.Low, c.Close}
+	return s.update(id, bars{b, b}, c.Close, c.Start, c.End)
+}
+
+// update processes the open orders on a symbol against new market data covering
+// the time from start to at, and values the positions in the symbol. Executions
+// are timestamped with at. The caller must hold the lock.
+func (s *Simulator) update(id int, b bars, mark qapi.Money, start time.Time, at time.Time) []qapi.Execution {
+	if at.After(s.now) {
+		s.now = at
+	}
+
+	// Process accounts in a fixed order, so that simulations are repeatable
+	numbers := make([]string, 0, len(s.accounts))
+	for n := range s.accounts {
+		numbers = append(numbers, n)
+	}
+	sort.Strings(numbers)
+
+	exec := []qapi.Execution{}
+	for _, n := range numbers {
+		a := s.accounts[n]
+
+		for k := range a.orders {
+			o := &a.orders[k]
+			if o.SymbolID != id || !isOpen(*o) {
+				continue
+			}
+
+			// Orders placed before there was any market data date from the first data
+			if o.CreationTime.IsZero() {
+				o.CreationTime = &at
+			}
+
+			// Orders whose time in force ran out before the market data began can't
+			// be filled by it
+			session := a.session(o.ID, start)
+			if expired(*o, session, start) {
+				expire(o, start)
+				continue
+			}
+
+			if e, ok := s.process(a, o, b, at); ok {
+				exec = append(exec, e)
+				continue
+			}
+
+			if isOpen(*o) && expired(*o, session, at) {
+				expire(o, at)
+			}
+		}
+
+		for k := range a.positions {
+			if a.positions[k].SymbolID == id {
+				markPosition(&a.positions[k], mark)
+			}
+		}
+	}
+
+	return exec
+}
+
+// process fills an order if the market data allows it. Orders that must be
+// filled immediately are canceled if they can't be. The caller must hold the lock.
+func (s *Simulator) process(a *account, o *qapi.Order, b bars, at time.Time) (qapi.Execution, bool) {
+	buy := o.Side.IsBuy()
+	prices := b.sell
+	if buy {
+		prices = b.buy
+	}
+
+	price, ok := s.fillPrice(o, prices, buy, at)
+	if ok {
+		return s.execute(a, o, price, buy, at)
+	}
+
+	if o.TimeInForce == qapi.TimeInForceImmediateOrCancel || o.TimeInForce == qapi.TimeInForceFillOrKill {
+		o.State = qapi.OrderStateCanceled
+		o.CanceledQuantity = o.OpenQuantity
+		o.OpenQuantity = 0
+		o.UpdateTime = &at
+	}
+	return qapi.Execution{}, false
+}
+
+// fillPrice determines the price an order can be filled at within a bar, if any.
+// Stop orders that reach their stop price are triggered, and become market or
+// limit orders from then on.
+func (s *Simulator) fillPrice(o *qapi.Order, b bar, buy bool, at time.Time) (qapi.Money, bool) {
+	start := b.open
+
+	stop := o.OrderType == qapi.OrderTypeStop || o.OrderType == qapi.OrderTypeStopLimit
+	if stop && o.State != qapi.OrderStateTriggered {
+		t, ok := triggerPrice(buy, o.StopPrice, b)
+		if !ok {
+			return qapi.Money{}, false
+		}
+		o.State = qapi.OrderStateTriggered
+		o.TriggerStopPrice = o.StopPrice
+		o.UpdateTime = &at
+		start = t
+	}
+
+	switch o.OrderType {
+	case qapi.OrderTypeMarket, qapi.OrderTypeStop:
+		return s.slip(o.Side, start), true
+	}
+
+	price, ok := limitPrice(buy, o.LimitPrice, start, b)
+	if !ok {
+		return qapi.Money{}, false
+	}
+
+	// Slippage can't make a limit order worse than its limit
+	price = s.slip(o.Side, price)
+	if (buy && price.Cmp(o.LimitPrice) > 0) || (!buy && price.Cmp(o.LimitPrice) < 0) {
+		price = o.LimitPrice
+	}
+	return price, true
+}
+
+// triggerPrice determines the price at which a stop price is reached within a
+// bar, if it is
+func triggerPrice(buy bool, stop qapi.Money, b bar) (qapi.Money, bool) {
+	if buy {
+		if b.high.Cmp(stop) < 0 {
+			return qapi.Money{}, false
+		}
+		if b.open.Cmp(stop) > 0 {
+			return b.open, true
+		}
+		return stop, true
+	}
+
+	if b.low.Cmp(stop) > 0 {
+		return qapi.Money{}, false
+	}
+	if b.open.Cmp(stop) < 0 {
+		return b.open, true
+	}
+	return stop, true
+}
+
+// limitPrice determines the price at which a limit order is filled within a bar,
+// if it is. start is the first price in the bar that the order can be filled at.
+func limitPrice(buy bool, limit qapi.Money, start qapi.Money, b bar) (qapi.Money, bool) {
+	if buy {
+		switch {
+		case start.Cmp(limit) <= 0:
+			return start, true
+		case b.low.Cmp(limit) <= 0:
+			return limit, true
+		}
+		return qapi.Money{}, false
+	}
+
+	switch {
+	case start.Cmp(limit) >= 0:
+		return start, true
+	case b.high.Cmp(limit) >= 0:
+		return limit, true
+	}
+	return qapi.Money{}, false
+}
+
+// slip applies the simulator's slippage to a price
+func (s *Simulator) slip(side qapi.OrderSide, price qapi.Money) qapi.Money {
+	if s.slippage == nil {
+		return price
+	}
+	return s.slippage(side, price)
+}
+
+// execute fills the open quantity of an order at a price, and updates the account.
+// The order is rejected if the account can't pay for it, or doesn't hold the
+// quantity being sold. The caller must hold the lock.
+func (s *Simulator) execute(a *account, o *qapi.Order, price qapi.Money, buy bool, at time.Time) (qapi.Execution, bool) {
+	qty := o.OpenQuantity
+	e := qapi.Execution{
+		Symbol:       o.Symbol,
+		SymbolID:     o.SymbolID,
+		Quantity:     qty,
+		Side:         o.Side,
+		Price:        price,
+		OrderID:      o.ID,
+		OrderChainID: o.ChainID,
+		Timestamp:    at,
+		Venue:        "SIM",
+		TotalCost:    price.MulInt(int64(qty)),
+	}
+	if s.commission != nil {
+		e.Commission = s.commission(e)
+	}
+
+	short := o.Side == qapi.SideShort || o.Side == qapi.SideSTO
+	reason := ""
+	switch {
+	case buy && a.cash.Cmp(e.TotalCost.Add(e.Commission)) < 0:
+		reason = "Insufficient buying power"
+	case !buy && !short && a.held(o.SymbolID) < int64(qty):
+		reason = "Insufficient position"
+	}
+	if reason != "" {
+		o.State = qapi.OrderStateRejected
+		o.ClientReasonStr = reason
+		o.CanceledQuantity = o.OpenQuantity
+		o.OpenQuantity = 0
+		o.UpdateTime = &at
+		return qapi.Execution{}, false
+	}
+
+	s.execSeq++
+	e.ID = s.execSeq
+
+	if buy {
+		a.cash = a.cash.Sub(e.TotalCost).Sub(e.Commission)
+	} else {
+		a.cash = a.cash.Add(e.TotalCost).Sub(e.Commission)
+	}
+	applyExecution(a.position(o.SymbolID, o.Symbol), price, qty, buy)
+
+	o.FilledQuantity += qty
+	o.OpenQuantity = 0
+	o.AvgExecPrice = price
+	o.LastExecPrice = price
+	o.CommissionCharged = o.CommissionCharged.Add(e.Commission)
+	o.State = qapi.OrderStateExecuted
+	o.UpdateTime = &at
+
+	a.executions = append(a.executions, e)
+	return e, true
+}
+
+// held returns the open quantity of the account's position in a symbol
+func (a *account) held(id int) int64 {
+	for _, p := range a.positions {
+		if p.SymbolID == id {
+			return int64(p.OpenQuantity)
+		}
+	}
+	return 0
+}
+
+// applyExecution updates a position with an execution. The part of the execution
+// that offsets the position realizes profit or loss against the average entry
+// price, and the rest is added to the position at the execution price.
+func applyExecution(p *qapi.Position, price qapi.Money, qty int, buy bool) {
+	held := int64(p.OpenQuantity)
+	delta := int64(qty)
+	if !buy {
+		delta = -delta
+	}
+	avg := p.AverageEntryPrice
+
+	if held != 0 && (held > 0) != (delta > 0) {
+		closed := abs(delta)
+		if abs(held) < closed {
+			closed = abs(held)
+		}
+
+		pnl := price.Sub(avg).MulInt(closed)
+		if held < 0 {
+			pnl = pnl.Neg()
+		}
+		p.ClosedPnL = p.ClosedPnL.Add(pnl)
+		p.ClosedQuantity += float32(closed)
+
+		if held > 0 {
+			held, delta = held-closed, delta+closed
+		} else {
+			held, delta = held+closed, delta-closed
+		}
+	}
+
+	if delta != 0 {
+		cost := avg.MulInt(abs(held)).Add(price.MulInt(abs(delta)))
+		held += delta
+		avg = cost.DivInt(abs(held))
+	}
+	if held == 0 {
+		avg = qapi.Money{}
+	}
+
+	p.OpenQuantity = float32(held)
+	p.AverageEntryPrice = avg
+	p.TotalCost = avg.MulInt(held)
+
+	mark := p.CurrentPrice
+	if mark.IsZero() {
+		mark = price
+	}
+	markPosition(p, mark)
+}
+
+// markPosition values a position at a price
+func markPosition(p *qapi.Position, price qapi.Money) {
+	p.CurrentPrice = price
+	p.CurrentMarketValue = price.MulInt(int64(p.OpenQuantity))
+	p.OpenPnL = p.CurrentMarketValue.Sub(p.TotalCost)
+}
+
+// session returns the start of the first market data that an order was checked
+// against, recording t as that time if it hasn't been checked yet. Day orders
+// are good for the date of their first market data. The caller must hold the lock.
+func (a *account) session(id int, t time.Time) time.Time {
+	if first, ok := a.sessions[id]; ok {
+		return first
+	}
+	a.sessions[id] = t
+	return t
+}
+
+// expired determines whether an order's time in force has run out by the given
+// time. session is the start of the first market data the order was checked against.
+func expired(o qapi.Order, session time.Time, at time.Time) bool {
+	switch o.TimeInForce {
+	case qapi.TimeInForceDay, qapi.TimeInForceGoodTillExtendedDay:
+		return date(at, session.Location()).After(date(session, session.Location()))
+	case qapi.TimeInForceGoodTillDate:
+		return o.GtdDate != nil && date(at, o.GtdDate.Location()).After(date(*o.GtdDate, o.GtdDate.Location()))
+	}
+	return false
+}
+
+// expire closes an order whose time in force has run out
+func expire(o *qapi.Order, at time.Time) {
+	o.State = qapi.OrderStateExpired
+	o.CanceledQuantity = o.OpenQuantity
+	o.OpenQuantity = 0
+	o.UpdateTime = &at
+}
+
+// date returns the calendar date of a time in the given location
+func date(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,407 @@
+// Package sim is a deterministic, in-process paper trading broker for qapi.
+//
+// A Simulator accepts the same order requests as the Questrade API, and fills
+// Market, Limit, Stop and StopLimit orders against the quotes or candlesticks it
+// is given. It keeps track of the positions, balances, orders and executions of
+// its accounts, and reports them with the same types as qapi.Client. Both
+// implement qapi.Trader, so a strategy can be switched between live and simulated
+// trading:
+//
+//	s := sim.New()
+//	s.AddAccount("SIM-1", qapi.USD, qapi.MustParseMoney("100000"))
+//
+//	var trader qapi.Trader = s // or a *qapi.Client
+//	orders, err := trader.PlaceOrderContext(ctx, req)
+//
+//	for _, c := range candles {
+//		s.Candle(symbolID, c)
+//	}
+//
+// The simulation is deliberately simple. Orders are always filled in full,
+// margin is not simulated, and buy orders are rejected if the account doesn't
+// have the cash to pay for them when they are filled.
+package sim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// CommissionFunc returns the commission charged for an execution.
+type CommissionFunc func(e qapi.Execution) qapi.Money
+
+// SlippageFunc returns the price that an order is actually filled at, given the
+// side of the order and the price that the market data allows it to be filled at.
+// Limit orders are never filled at a price worse than their limit, regardless of
+// slippage.
+type SlippageFunc func(side qapi.OrderSide, price qapi.Money) qapi.Money
+
+// Option customizes a simulator created with New.
+type Option func(*Simulator)
+
+// WithCommission sets the function used to charge commissions on executions. By
+// default no commission is charged.
+func WithCommission(f CommissionFunc) Option {
+	return func(s *Simulator) {
+		s.commission = f
+	}
+}
+
+// WithSlippage sets the function used to apply slippage to fill prices. By default
+// orders are filled at the price the market data allows.
+func WithSlippage(f SlippageFunc) Option {
+	return func(s *Simulator) {
+		s.slippage = f
+	}
+}
+
+// Simulator is a paper trading broker. It is safe for concurrent use.
+type Simulator struct {
+	mu         sync.Mutex
+	commission CommissionFunc
+	slippage   SlippageFunc
+	accounts   map[string]*account
+	symbols    map[int]string
+	quotes     map[int]qapi.Quote
+	candles    map[int]qapi.Candlestick
+	now        time.Time
+	orderSeq   int
+	execSeq    int
+}
+
+// account is the state of a simulated account
+type account struct {
+	number     string
+	currency   qapi.Currency
+	cash       qapi.Money
+	positions  []qapi.Position
+	orders     []qapi.Order
+	executions []qapi.Execution
+	sessions   map[int]time.Time // start of the first market data each order was checked against
+}
+
+// New creates a simulator without any accounts.
+func New(opts ...Option) *Simulator {
+	s := &Simulator{
+		accounts: map[string]*account{},
+		symbols:  map[int]string{},
+		quotes:   map[int]qapi.Quote{},
+		candles:  map[int]qapi.Candlestick{},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// AddAccount opens an account with a cash balance in the given currency. Any
+// existing account with the same number is replaced.
+func (s *Simulator) AddAccount(number string, currency qapi.Currency, cash qapi.Money) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.accounts[number] = &account{number: number, currency: currency, cash: cash, sessions: map[int]time.Time{}}
+}
+
+// AddSymbol sets the name of a symbol, which is used in the orders, positions and
+// executions of the symbol. Names are also taken from quotes.
+func (s *Simulator) AddSymbol(id int, symbol string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.symbols[id] = symbol
+}
+
+// Now returns the simulated time, which is the time of the latest market data.
+func (s *Simulator) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// account returns an account by number. The caller must hold the lock.
+func (s *Simulator) account(number string) (*account, error) {
+	a, ok := s.accounts[number]
+	if !ok {
+		return nil, fmt.Errorf("Error: Unknown account %s", number)
+	}
+	return a, nil
+}
+
+// GetQuotesContext returns the latest quotes of the symbols. Symbols that have
+// only been given candlesticks are quoted at the close of the latest one.
+// Symbols without market data are left out.
+func (s *Simulator) GetQuotesContext(ctx context.Context, ids ...int) ([]qapi.Quote, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	quotes := []qapi.Quote{}
+	for _, id := range ids {
+		if q, ok := s.quotes[id]; ok {
+			quotes = append(quotes, q)
+		} else if c, ok := s.candles[id]; ok {
+			quotes = append(quotes, qapi.Quote{
+				Symbol:         s.symbols[id],
+				SymbolID:       id,
+				BidPrice:       c.Close,
+				AskPrice:       c.Close,
+				LastTradePrice: c.Close,
+				LastTradeTrHrs: c.Close,
+				OpenPrice:      c.Open,
+				HighPrice:      c.High,
+				LowPrice:       c.Low,
+				Volume:         c.Volume,
+			})
+		}
+	}
+	return quotes, nil
+}
+
+// GetBalancesContext returns the balances of an account. Buying power and
+// maintenance excess are equal to the cash balance, since margin is not
+// simulated.
+func (s *Simulator) GetBalancesContext(ctx context.Context, number string) (qapi.AccountBalances, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.account(number)
+	if err != nil {
+		return qapi.AccountBalances{}, err
+	}
+
+	var value qapi.Money
+	for _, p := range a.positions {
+		value = value.Add(p.CurrentMarketValue)
+	}
+
+	b := qapi.Balance{
+		Currency:          a.currency,
+		Cash:              a.cash,
+		MarketValue:       value,
+		TotalEquity:       a.cash.Add(value),
+		BuyingPower:       a.cash,
+		MaintenanceExcess: a.cash,
+		IsRealTime:        true,
+	}
+
+	return qapi.AccountBalances{
+		PerCurrencyBalances: []qapi.Balance{b},
+		CombinedBalances:    []qapi.Balance{b},
+	}, nil
+}
+
+// GetPositionsContext returns the positions of an account, including positions
+// that have been closed.
+func (s *Simulator) GetPositionsContext(ctx context.Context, number string) ([]qapi.Position, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.account(number)
+	if err != nil {
+		return []qapi.Position{}, err
+	}
+	return append([]qapi.Position{}, a.positions...), nil
+}
+
+// GetExecutionsContext returns the executions of an account at or after the start
+// time and before the end time. Zero-value times are unlimited.
+func (s *Simulator) GetExecutionsContext(ctx context.Context, number string, start time.Time, end time.Time) ([]qapi.Execution, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.account(number)
+	if err != nil {
+		return []qapi.Execution{}, err
+	}
+
+	rng := qapi.TimeRange{Start: start, End: end}
+	exec := []qapi.Execution{}
+	for _, e := range a.executions {
+		if rng.Contains(e.Timestamp) {
+			exec = append(exec, e)
+		}
+	}
+	return exec, nil
+}
+
+// GetOrdersContext returns the orders of an account created at or after the start
+// time and before the end time, in the given state. Zero-value times are unlimited.
+func (s *Simulator) GetOrdersContext(ctx context.Context, number string, start time.Time, end time.Time, state qapi.OrderStateFilter) ([]qapi.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.account(number)
+	if err != nil {
+		return []qapi.Order{}, err
+	}
+
+	rng := qapi.TimeRange{Start: start, End: end}
+	orders := []qapi.Order{}
+	for _, o := range a.orders {
+		if !rng.Contains(*o.CreationTime) {
+			continue
+		}
+		if (state == qapi.OrderFilterOpen && !isOpen(o)) || (state == qapi.OrderFilterClosed && isOpen(o)) {
+			continue
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// GetOrdersByIDContext returns the orders of an account with the given ID's.
+func (s *Simulator) GetOrdersByIDContext(ctx context.Context, number string, orderIds ...int) ([]qapi.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.account(number)
+	if err != nil {
+		return []qapi.Order{}, err
+	}
+
+	orders := []qapi.Order{}
+	for _, id := range orderIds {
+		if o := a.order(id); o != nil {
+			orders = append(orders, *o)
+		}
+	}
+	return orders, nil
+}
+
+// PlaceOrderContext places an order, or replaces an open order if the request has
+// an order ID. The request is checked with OrderRequest.Validate, and only
+// Market, Limit, Stop and StopLimit orders are supported. If the symbol has a
+// quote, the order is filled right away if the quote allows it - otherwise it
+// waits for the next market data. Orders placed after a candlestick wait for the
+// next one.
+//
+// ImmediateOrCancel and FillOrKill orders are canceled if they can't be filled
+// by the first market data they are checked against. Day orders are good for the
+// date on which that market data starts, so a Day order placed after a daily
+// candlestick is good for the next one, and expire once they haven't been filled
+// by the end of that date. GoodTillDate orders expire once they haven't been
+// filled by the end of their date.
+func (s *Simulator) PlaceOrderContext(ctx context.Context, req qapi.OrderRequest) ([]qapi.Order, error) {
+	if err := req.Validate(); err != nil {
+		return []qapi.Order{}, err
+	}
+
+	switch req.OrderType {
+	case qapi.OrderTypeMarket, qapi.OrderTypeLimit, qapi.OrderTypeStop, qapi.OrderTypeStopLimit:
+	default:
+		return []qapi.Order{}, fmt.Errorf("Error: Order type %s is not supported by the simulator", req.OrderType)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.account(req.AccountID)
+	if err != nil {
+		return []qapi.Order{}, err
+	}
+
+	now := s.now
+	s.orderSeq++
+	o := qapi.Order{
+		ID:                    s.orderSeq,
+		Symbol:                s.symbols[req.SymbolID],
+		SymbolID:              req.SymbolID,
+		TotalQuantity:         req.Quantity,
+		OpenQuantity:          req.Quantity,
+		Side:                  req.Action,
+		OrderType:             req.OrderType,
+		LimitPrice:            req.LimitPrice,
+		StopPrice:             req.StopPrice,
+		IsAllOrNone:           req.IsAllOrNone,
+		IsAnonymous:           req.IsAnonymous,
+		IcebergQuantity:       req.IcebergQuantity,
+		TimeInForce:           req.TimeInForce,
+		GtdDate:               req.GtdDate,
+		State:                 qapi.OrderStateAccepted,
+		ChainID:               s.orderSeq,
+		CreationTime:          &now,
+		UpdateTime:            &now,
+		PrimaryRoute:          req.PrimaryRoute,
+		SecondaryRoute:        req.SecondaryRoute,
+		IsLimitOffsetInDollar: req.IsLimitOffsetInDollar,
+	}
+
+	if req.OrderID != 0 {
+		orig := a.order(req.OrderID)
+		if orig == nil || !isOpen(*orig) {
+			return []qapi.Order{}, fmt.Errorf("Error: Order %d can't be replaced", req.OrderID)
+		}
+		orig.State = qapi.OrderStateReplaced
+		orig.UpdateTime = &now
+		o.ChainID = orig.ChainID
+	}
+
+	a.orders = append(a.orders, o)
+	placed := &a.orders[len(a.orders)-1]
+
+	if q, ok := s.quotes[req.SymbolID]; ok && !q.BidPrice.IsZero() && !q.AskPrice.IsZero() {
+		a.session(placed.ID, now)
+		s.process(a, placed, quoteBars(q), now)
+	}
+
+	return []qapi.Order{*placed}, nil
+}
+
+// DeleteOrderContext cancels an open order.
+func (s *Simulator) DeleteOrderContext(ctx context.Context, acctNum string, orderID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, err := s.account(acctNum)
+	if err != nil {
+		return err
+	}
+
+	o := a.order(orderID)
+	if o == nil {
+		return fmt.Errorf("Error: Unknown order %d", orderID)
+	}
+	if !isOpen(*o) {
+		return errors.New("Error: Order is not open")
+	}
+
+	now := s.now
+	o.State = qapi.OrderStateCanceled
+	o.CanceledQuantity = o.OpenQuantity
+	o.OpenQuantity = 0
+	o.UpdateTime = &now
+	return nil
+}
+
+var _ qapi.Trader = (*Simulator)(nil)
+
+// order returns a pointer to an order of the account, or nil if it doesn't exist
+func (a *account) order(id int) *qapi.Order {
+	for k := range a.orders {
+		if a.orders[k].ID == id {
+			return &a.orders[k]
+		}
+	}
+	return nil
+}
+
+// position returns a pointer to the account's position in a symbol, creating it
+// if the account has never held the symbol
+func (a *account) position(id int, symbol string) *qapi.Position {
+	for k := range a.positions {
+		if a.positions[k].SymbolID == id {
+			return &a.positions[k]
+		}
+	}
+	a.positions = append(a.positions, qapi.Position{Symbol: symbol, SymbolID: id, IsRealTime: true})
+	return &a.positions[len(a.positions)-1]
+}
+
+// isOpen determines whether an order can still be filled
+func isOpen(o qapi.Order) bool {
+	return o.State == qapi.OrderStateAccepted || o.State == qapi.OrderStateTriggered
+}
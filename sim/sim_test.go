@@ -0,0 +1,200 @@
+package sim_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/sim"
+)
+
+const (
+	account = "SIM-1"
+	symbol  = 8049
+)
+
+var est = time.FixedZone("EST", -5*60*60)
+
+// newSim returns a simulator with an account holding $100,000
+func newSim() *sim.Simulator {
+	s := sim.New()
+	s.AddAccount(account, qapi.USD, qapi.MustParseMoney("100000"))
+	return s
+}
+
+// day returns a daily candlestick of the symbol, stamped like the Questrade API
+// stamps them: from midnight to the following midnight
+func day(y int, m time.Month, d int, open, high, low, close string) qapi.Candlestick {
+	start := time.Date(y, m, d, 0, 0, 0, 0, est)
+	return qapi.Candlestick{
+		Start: start,
+		End:   start.AddDate(0, 0, 1),
+		Open:  qapi.MustParseMoney(open),
+		High:  qapi.MustParseMoney(high),
+		Low:   qapi.MustParseMoney(low),
+		Close: qapi.MustParseMoney(close),
+	}
+}
+
+// quote returns a quote of the symbol
+func quote(bid, ask string) qapi.Quote {
+	return qapi.Quote{
+		SymbolID:       symbol,
+		BidPrice:       qapi.MustParseMoney(bid),
+		AskPrice:       qapi.MustParseMoney(ask),
+		LastTradePrice: qapi.MustParseMoney(bid),
+	}
+}
+
+// place places an order for 100 shares of the symbol, and returns its ID
+func place(t *testing.T, s *sim.Simulator, side qapi.OrderSide, orderType qapi.OrderType, tif qapi.TimeInForce, limit, stop string) int {
+	t.Helper()
+	req := qapi.OrderRequest{
+		AccountID:   account,
+		SymbolID:    symbol,
+		Quantity:    100,
+		Action:      side,
+		OrderType:   orderType,
+		TimeInForce: tif,
+	}
+	if limit != "" {
+		req.LimitPrice = qapi.MustParseMoney(limit)
+	}
+	if stop != "" {
+		req.StopPrice = qapi.MustParseMoney(stop)
+	}
+
+	orders, err := s.PlaceOrderContext(context.Background(), req)
+	if err != nil {
+		t.Fatalf("PlaceOrderContext: %v", err)
+	}
+	return orders[0].ID
+}
+
+// order returns an order of the account
+func order(t *testing.T, s *sim.Simulator, id int) qapi.Order {
+	t.Helper()
+	orders, err := s.GetOrdersByIDContext(context.Background(), account, id)
+	if err != nil || len(orders) != 1 {
+		t.Fatalf("GetOrdersByIDContext(%d) = %v %v", id, orders, err)
+	}
+	return orders[0]
+}
+
+// checkOrder checks the state of an order and the price it was filled at
+func checkOrder(t *testing.T, s *sim.Simulator, id int, state qapi.OrderState, price string) {
+	t.Helper()
+	o := order(t, s, id)
+	if o.State != state {
+		t.Errorf("order %d state = %s, want %s", id, o.State, state)
+	}
+	if price != "" && o.AvgExecPrice.String() != qapi.MustParseMoney(price).String() {
+		t.Errorf("order %d filled at %s, want %s", id, o.AvgExecPrice, price)
+	}
+}
+
+func TestDayOrderFillsOnNextDailyCandle(t *testing.T) {
+	s := newSim()
+	s.Candle(symbol, day(2020, 1, 6, "10", "11", "9", "10.50"))
+
+	id := place(t, s, qapi.SideBuy, qapi.OrderTypeMarket, qapi.TimeInForceDay, "", "")
+	if e := s.Candle(symbol, day(2020, 1, 7, "10.75", "12", "10", "11")); len(e) != 1 {
+		t.Fatalf("Candle returned %d executions, want 1", len(e))
+	}
+	checkOrder(t, s, id, qapi.OrderStateExecuted, "10.75")
+
+	// A Day order placed after Friday's candle is good for Monday's
+	s.Candle(symbol, day(2020, 1, 10, "11", "11", "11", "11"))
+	id = place(t, s, qapi.SideSell, qapi.OrderTypeMarket, qapi.TimeInForceDay, "", "")
+	s.Candle(symbol, day(2020, 1, 13, "11.25", "12", "11", "11.50"))
+	checkOrder(t, s, id, qapi.OrderStateExecuted, "11.25")
+}
+
+func TestDayOrderExpires(t *testing.T) {
+	s := newSim()
+	s.Candle(symbol, day(2020, 1, 6, "10", "11", "9", "10.50"))
+
+	// The limit isn't reached during the order's day, so it expires at the end
+	// of it, and isn't filled by the next day's prices
+	id := place(t, s, qapi.SideBuy, qapi.OrderTypeLimit, qapi.TimeInForceDay, "9.50", "")
+	s.Candle(symbol, day(2020, 1, 7, "10", "11", "9.75", "10"))
+	checkOrder(t, s, id, qapi.OrderStateExpired, "")
+	s.Candle(symbol, day(2020, 1, 8, "9", "9", "9", "9"))
+	checkOrder(t, s, id, qapi.OrderStateExpired, "")
+	if o := order(t, s, id); o.CanceledQuantity != 100 || o.OpenQuantity != 0 {
+		t.Errorf("expired order quantities = %d canceled, %d open, want 100 and 0", o.CanceledQuantity, o.OpenQuantity)
+	}
+
+	// A Day order placed during the session with a quote expires with the
+	// session, even if the next day's first quote would fill it
+	s.Quote(quote("10", "10.05"), time.Date(2020, 1, 9, 15, 0, 0, 0, est))
+	id = place(t, s, qapi.SideBuy, qapi.OrderTypeLimit, qapi.TimeInForceDay, "9.50", "")
+	checkOrder(t, s, id, qapi.OrderStateAccepted, "")
+	s.Quote(quote("9.30", "9.40"), time.Date(2020, 1, 10, 9, 30, 0, 0, est))
+	checkOrder(t, s, id, qapi.OrderStateExpired, "")
+}
+
+func TestGoodTillCanceledOrder(t *testing.T) {
+	s := newSim()
+	s.Candle(symbol, day(2020, 1, 6, "10", "11", "9", "10.50"))
+
+	id := place(t, s, qapi.SideBuy, qapi.OrderTypeLimit, qapi.TimeInForceGoodTillCanceled, "9.50", "")
+	s.Candle(symbol, day(2020, 1, 7, "10", "11", "9.75", "10"))
+	s.Candle(symbol, day(2020, 1, 8, "10", "10.50", "9.80", "10"))
+	checkOrder(t, s, id, qapi.OrderStateAccepted, "")
+
+	// The market gaps through the limit, so the order is filled at the open
+	s.Candle(symbol, day(2020, 1, 9, "9.25", "9.60", "9", "9.40"))
+	checkOrder(t, s, id, qapi.OrderStateExecuted, "9.25")
+}
+
+func TestImmediateOrders(t *testing.T) {
+	for _, tif := range []qapi.TimeInForce{qapi.TimeInForceImmediateOrCancel, qapi.TimeInForceFillOrKill} {
+		// With a quote, the order is checked as soon as it's placed
+		s := newSim()
+		s.Quote(quote("10", "10.05"), time.Date(2020, 1, 6, 10, 0, 0, 0, est))
+		id := place(t, s, qapi.SideBuy, qapi.OrderTypeLimit, tif, "10", "")
+		checkOrder(t, s, id, qapi.OrderStateCanceled, "")
+		id = place(t, s, qapi.SideBuy, qapi.OrderTypeLimit, tif, "10.05", "")
+		checkOrder(t, s, id, qapi.OrderStateExecuted, "10.05")
+
+		// After a candle, it's checked against the next one only
+		s = newSim()
+		s.Candle(symbol, day(2020, 1, 6, "10", "11", "9", "10.50"))
+		id = place(t, s, qapi.SideBuy, qapi.OrderTypeLimit, tif, "9.50", "")
+		s.Candle(symbol, day(2020, 1, 7, "10", "11", "9.75", "10"))
+		checkOrder(t, s, id, qapi.OrderStateCanceled, "")
+		s.Candle(symbol, day(2020, 1, 8, "9", "9", "9", "9"))
+		checkOrder(t, s, id, qapi.OrderStateCanceled, "")
+	}
+}
+
+func TestStopOrders(t *testing.T) {
+	s := newSim()
+	s.Candle(symbol, day(2020, 1, 6, "10", "10.50", "9.50", "10"))
+
+	// A stop buy becomes a market order once the price reaches the stop, and is
+	// filled at the stop, or at the open if the market gapped through it
+	stop := place(t, s, qapi.SideBuy, qapi.OrderTypeStop, qapi.TimeInForceGoodTillCanceled, "", "11")
+	gap := place(t, s, qapi.SideBuy, qapi.OrderTypeStop, qapi.TimeInForceGoodTillCanceled, "", "10.75")
+	s.Candle(symbol, day(2020, 1, 7, "10", "10.60", "9.90", "10.20"))
+	checkOrder(t, s, stop, qapi.OrderStateAccepted, "")
+	checkOrder(t, s, gap, qapi.OrderStateAccepted, "")
+	s.Candle(symbol, day(2020, 1, 8, "10.80", "11.50", "10.70", "11.20"))
+	checkOrder(t, s, stop, qapi.OrderStateExecuted, "11")
+	checkOrder(t, s, gap, qapi.OrderStateExecuted, "10.80")
+
+	// A stop limit sell is triggered when the bid reaches the stop, and is then
+	// a limit order until the bid reaches its limit
+	s.Quote(quote("11", "11.05"), time.Date(2020, 1, 9, 10, 0, 0, 0, est))
+	id := place(t, s, qapi.SideSell, qapi.OrderTypeStopLimit, qapi.TimeInForceGoodTillCanceled, "10.90", "10.95")
+	checkOrder(t, s, id, qapi.OrderStateAccepted, "")
+	s.Quote(quote("10.80", "10.85"), time.Date(2020, 1, 9, 10, 1, 0, 0, est))
+	checkOrder(t, s, id, qapi.OrderStateTriggered, "")
+	if o := order(t, s, id); o.TriggerStopPrice.String() != "10.95" {
+		t.Errorf("trigger stop price = %s, want 10.95", o.TriggerStopPrice)
+	}
+	s.Quote(quote("10.92", "10.97"), time.Date(2020, 1, 9, 10, 2, 0, 0, est))
+	checkOrder(t, s, id, qapi.OrderStateExecuted, "10.92")
+}
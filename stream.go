@@ -0,0 +1,654 @@
+package qapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamPingInterval is how often a ping is sent to keep a streaming connection alive
+const streamPingInterval = 30 * time.Second
+
+// streamReadTimeout is how long a streaming connection can go without receiving
+// anything from the server before it is considered dead and reconnected
+const streamReadTimeout = 2 * time.Minute
+
+// errStreamRestart is used to reconnect a stream without any delay
+var errStreamRestart = errors.New("Error: Stream restarted")
+
+// tlsConfig returns the TLS configuration of the client's transport, so that
+// streaming connections are made with the same settings as API requests.
+func (c *Client) tlsConfig() *tls.Config {
+	if t, ok := c.httpClient.Transport.(*http.Transport); ok {
+		return t.TLSClientConfig
+	}
+	return nil
+}
+
+// openStream requests a streaming port from an endpoint, connects to it, and
+// authenticates the connection with the access token.
+// Ref: http://www.questrade.com/api/documentation/streaming
+func (c *Client) openStream(ctx context.Context, endpoint string, query url.Values) (*wsConn, error) {
+	port := struct {
+		StreamPort int `json:"streamPort"`
+	}{}
+
+	err := c.get(ctx, endpoint, &port, query)
+	if err != nil {
+		return nil, err
+	}
+
+	creds := c.credentials()
+	u, err := url.Parse(creds.ApiServer)
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "wss"
+	if u.Scheme == "http" {
+		scheme = "ws"
+	}
+
+	addr := scheme + "://" + net.JoinHostPort(u.Hostname(), strconv.Itoa(port.StreamPort)) + "/"
+	ws, err := dialWebSocket(ctx, addr, c.tlsConfig())
+	if err != nil {
+		return nil, err
+	}
+	ws.readTimeout = streamReadTimeout
+
+	err = ws.WriteMessage(wsText, []byte(creds.AccessToken))
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+
+	_, msg, err := ws.ReadMessage()
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+
+	res := struct {
+		Success bool   `json:"success"`
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}{}
+
+	if err := json.Unmarshal(msg, &res); err != nil || !res.Success {
+		ws.Close()
+		return nil, fmt.Errorf("Error: Stream authentication failed: %s", msg)
+	}
+
+	return ws, nil
+}
+
+// stream keeps a streaming connection open, reconnecting with backoff whenever
+// it is dropped, until it is closed or fails with an error that can't be
+// recovered from.
+type stream struct {
+	c       *Client
+	ctx     context.Context
+	cancel  context.CancelFunc
+	connect func(ctx context.Context) (*wsConn, error)
+	handle  func(msg []byte) error
+	done    chan struct{}
+
+	mu         sync.Mutex // guards the fields below
+	conn       *wsConn
+	restarting bool
+	err        error
+}
+
+// newStream creates a stream that uses connect to establish connections, and
+// passes every message received to handle.
+func newStream(ctx context.Context, c *Client, connect func(context.Context) (*wsConn, error), handle func([]byte) error) *stream {
+	ctx, cancel := context.WithCancel(ctx)
+	return &stream{
+		c:       c,
+		ctx:     ctx,
+		cancel:  cancel,
+		connect: connect,
+		handle:  handle,
+		done:    make(chan struct{}),
+	}
+}
+
+// run serves the initial connection, then reconnects until the stream is done.
+// The finished function is called once the stream has stopped.
+func (s *stream) run(conn *wsConn, finished func()) {
+	defer close(s.done)
+	defer finished()
+
+	// The error from the last connection, or from the last attempt to connect
+	var err error
+	failures := 0
+	for {
+		if conn != nil {
+			err = s.serve(conn)
+		}
+
+		s.mu.Lock()
+		restarting := s.restarting
+		s.restarting = false
+		s.mu.Unlock()
+
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		if !restarting && err != nil {
+			if he, ok := err.(handlerError); ok {
+				s.fail(he.err)
+				return
+			}
+			if qe, ok := err.(QuestradeError); ok && !isTransient(qe) {
+				s.fail(err)
+				return
+			}
+
+			failures++
+			timer := time.NewTimer(s.c.RetryPolicy.backoff(failures, err))
+			select {
+			case <-timer.C:
+			case <-s.ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		conn, err = s.connect(s.ctx)
+		if err != nil {
+			conn = nil
+			continue
+		}
+		failures = 0
+	}
+}
+
+// fail stops the stream with an error that can't be recovered from
+func (s *stream) fail(err error) {
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+}
+
+// handlerError is an error returned by a stream's message handler, such as a
+// message that can't be decoded. Reconnecting would not help, since the server
+// would send the same messages again.
+type handlerError struct {
+	err error
+}
+
+func (h handlerError) Error() string {
+	return h.err.Error()
+}
+
+// serve reads messages from the connection until it fails
+func (s *stream) serve(conn *wsConn) error {
+	// The connection may already be out of date if it was restarted while connecting
+	s.mu.Lock()
+	if s.restarting {
+		s.mu.Unlock()
+		conn.Close()
+		return errStreamRestart
+	}
+	s.conn = conn
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		s.conn = nil
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	// Keep the connection alive while waiting for messages
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(streamPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if conn.Ping() != nil {
+					return
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	for {
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+
+		if err := s.handle(msg); err != nil {
+			return handlerError{err}
+		}
+	}
+}
+
+// restart drops the current connection, so that a new one is made right away
+func (s *stream) restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.restarting = true
+	if s.conn != nil {
+		s.conn.Close()
+	}
+}
+
+// close stops the stream, and waits for it to finish
+func (s *stream) close() {
+	s.cancel()
+
+	s.mu.Lock()
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.mu.Unlock()
+
+	<-s.done
+}
+
+// error returns the error that stopped the stream, if any
+func (s *stream) error() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// QuoteStream delivers Level 1 quotes for a set of symbols as they change. The
+// connection is kept alive with heartbeats, and is reconnected automatically if
+// it drops.
+type QuoteStream struct {
+	s       *stream
+	quotes  chan Quote
+	changed chan struct{}
+	mu      sync.Mutex
+	ids     map[int]bool
+}
+
+// StreamQuotes opens a stream of Level 1 quotes for the given symbols. The stream
+// runs until it is closed, or the context is done.
+//
+// Ref: http://www.questrade.com/api/documentation/streaming
+func (c *Client) StreamQuotes(ctx context.Context, ids ...int) (*QuoteStream, error) {
+	if len(ids) == 0 {
+		return nil, errors.New("Error: No symbols to stream")
+	}
+
+	q := &QuoteStream{
+		quotes:  make(chan Quote, 256),
+		changed: make(chan struct{}, 1),
+		ids:     map[int]bool{},
+	}
+	for _, id := range ids {
+		q.ids[id] = true
+	}
+
+	q.s = newStream(ctx, c, q.connect, q.handle)
+
+	// Connect before returning so that bad requests are reported right away
+	conn, err := q.connect(q.s.ctx)
+	if err != nil {
+		q.s.cancel()
+		return nil, err
+	}
+
+	go q.s.run(conn, func() { close(q.quotes) })
+	return q, nil
+}
+
+// connect opens a streaming connection for the subscribed symbols, waiting
+// until there is at least one symbol to subscribe to.
+func (q *QuoteStream) connect(ctx context.Context) (*wsConn, error) {
+	for {
+		ids := q.Symbols()
+		if len(ids) > 0 {
+			idStrs := make([]string, len(ids))
+			for k, v := range ids {
+				idStrs[k] = strconv.Itoa(v)
+			}
+
+			params := url.Values{}
+			params.Add("ids", strings.Join(idStrs, ","))
+			params.Add("stream", "true")
+			params.Add("mode", "WebSocket")
+
+			return q.s.c.openStream(ctx, "v1/markets/quotes", params)
+		}
+
+		select {
+		case <-q.changed:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// handle decodes a streaming message and delivers its quotes. Messages that
+// don't contain quotes are heartbeats, and are ignored.
+func (q *QuoteStream) handle(msg []byte) error {
+	m := struct {
+		Quotes []Quote `json:"quotes"`
+	}{}
+
+	if err := q.s.c.decode(msg, &m); err != nil {
+		return err
+	}
+
+	for _, quote := range m.Quotes {
+		select {
+		case q.quotes <- quote:
+		case <-q.s.ctx.Done():
+			return q.s.ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Quotes returns the channel on which quotes are delivered. The channel is
+// closed once the stream stops.
+func (q *QuoteStream) Quotes() <-chan Quote {
+	return q.quotes
+}
+
+// Symbols returns the IDs of the subscribed symbols, in ascending order
+func (q *QuoteStream) Symbols() []int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	ids := make([]int, 0, len(q.ids))
+	for id := range q.ids {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// Subscribe adds symbols to the stream. The stream reconnects to apply the change.
+func (q *QuoteStream) Subscribe(ids ...int) {
+	q.mu.Lock()
+	for _, id := range ids {
+		q.ids[id] = true
+	}
+	q.mu.Unlock()
+
+	q.update()
+}
+
+// Unsubscribe removes symbols from the stream. The stream reconnects to apply the
+// change. If no symbols are left, the stream waits until one is subscribed to.
+func (q *QuoteStream) Unsubscribe(ids ...int) {
+	q.mu.Lock()
+	for _, id := range ids {
+		delete(q.ids, id)
+	}
+	q.mu.Unlock()
+
+	q.update()
+}
+
+// update signals that the subscribed symbols have changed
+func (q *QuoteStream) update() {
+	select {
+	case q.changed <- struct{}{}:
+	default:
+	}
+	q.s.restart()
+}
+
+// Close stops the stream. The quote channel is closed once the stream has stopped.
+func (q *QuoteStream) Close() error {
+	q.s.close()
+	return nil
+}
+
+// Err returns the error that stopped the stream. It is nil while the stream is
+// running, and if the stream was stopped by Close or its context.
+func (q *QuoteStream) Err() error {
+	return q.s.error()
+}
+
+// Notification is an update about orders or executions in an account, delivered
+// by a NotificationStream.
+type Notification struct {
+	// Account to which the orders or executions belong.
+	AccountNumber string `json:"accountNumber"`
+
+	// Orders whose state has changed.
+	Orders []Order `json:"orders"`
+
+	// New executions.
+	Executions []Execution `json:"executions"`
+
+	// Whether the notification was recovered by querying the API after the stream
+	// reconnected, rather than being received from the stream.
+	Recovered bool `json:"-"`
+}
+
+// NotificationStream delivers order state changes and executions as they happen.
+// If the connection drops, the stream reconnects automatically, and recovers any
+// updates that were missed in the meantime by querying the API.
+type NotificationStream struct {
+	s        *stream
+	events   chan Notification
+	accounts []string
+
+	mu         sync.Mutex // guards the fields below
+	connected  bool
+	lastUpdate time.Time
+	lastOrders map[int]bool // IDs of the orders delivered with the last update time
+	lastExec   time.Time
+	lastExecs  map[int]bool // IDs of the executions delivered with the last timestamp
+}
+
+// StreamNotifications opens a stream of order and execution notifications. The
+// accounts are used to recover missed updates after reconnecting - if none are
+// given, all of the user's accounts are used. The stream runs until it is closed,
+// or the context is done.
+//
+// Ref: http://www.questrade.com/api/documentation/streaming
+func (c *Client) StreamNotifications(ctx context.Context, accounts ...string) (*NotificationStream, error) {
+	if len(accounts) == 0 {
+		_, accts, err := c.GetAccountsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range accts {
+			accounts = append(accounts, a.Number)
+		}
+	}
+
+	now := time.Now()
+	n := &NotificationStream{
+		events:     make(chan Notification, 64),
+		accounts:   accounts,
+		lastUpdate: now,
+		lastOrders: map[int]bool{},
+		lastExec:   now,
+		lastExecs:  map[int]bool{},
+	}
+
+	n.s = newStream(ctx, c, n.connect, n.handle)
+
+	conn, err := n.connect(n.s.ctx)
+	if err != nil {
+		n.s.cancel()
+		return nil, err
+	}
+
+	go n.s.run(conn, func() { close(n.events) })
+	return n, nil
+}
+
+// connect opens a streaming connection. When reconnecting, the updates made
+// since the last one seen are recovered once the new connection is open.
+func (n *NotificationStream) connect(ctx context.Context) (*wsConn, error) {
+	params := url.Values{}
+	params.Add("mode", "WebSocket")
+
+	conn, err := n.s.c.openStream(ctx, "v1/notifications", params)
+	if err != nil {
+		return nil, err
+	}
+
+	n.mu.Lock()
+	reconnect := n.connected
+	n.connected = true
+	n.mu.Unlock()
+
+	if reconnect {
+		if err := n.recover(ctx); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+// recover queries the orders and executions that were updated since the last
+// notification, and delivers them. Updates made at the same time as the last one
+// delivered are recovered too, unless they were delivered already.
+func (n *NotificationStream) recover(ctx context.Context) error {
+	n.mu.Lock()
+	since, execSince := n.lastUpdate, n.lastExec
+	seenOrders, seenExecs := copyIDs(n.lastOrders), copyIDs(n.lastExecs)
+	n.mu.Unlock()
+
+	now := time.Now()
+	for _, acct := range n.accounts {
+		orders, err := n.s.c.GetOrdersContext(ctx, acct, since, now, OrderFilterAll)
+		if err != nil {
+			return err
+		}
+
+		missed := Notification{AccountNumber: acct, Recovered: true}
+		for _, o := range orders {
+			if o.UpdateTime != nil && undelivered(*o.UpdateTime, o.ID, since, seenOrders) {
+				missed.Orders = append(missed.Orders, o)
+			}
+		}
+
+		execs, err := n.s.c.GetExecutionsContext(ctx, acct, execSince, now)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range execs {
+			if undelivered(e.Timestamp, e.ID, execSince, seenExecs) {
+				missed.Executions = append(missed.Executions, e)
+			}
+		}
+
+		if len(missed.Orders) > 0 || len(missed.Executions) > 0 {
+			if err := n.deliver(missed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// handle decodes a streaming message and delivers it. Messages that don't
+// contain orders or executions are heartbeats, and are ignored.
+func (n *NotificationStream) handle(msg []byte) error {
+	var note Notification
+	if err := n.s.c.decode(msg, &note); err != nil {
+		return err
+	}
+
+	if len(note.Orders) == 0 && len(note.Executions) == 0 {
+		return nil
+	}
+	return n.deliver(note)
+}
+
+// deliver sends a notification on the event channel, and records the time of the
+// latest update in it, and which orders and executions were updated at that time.
+func (n *NotificationStream) deliver(note Notification) error {
+	n.mu.Lock()
+	for _, o := range note.Orders {
+		if o.UpdateTime != nil {
+			n.lastUpdate = latest(n.lastUpdate, n.lastOrders, *o.UpdateTime, o.ID)
+		}
+	}
+	for _, e := range note.Executions {
+		n.lastExec = latest(n.lastExec, n.lastExecs, e.Timestamp, e.ID)
+	}
+	n.mu.Unlock()
+
+	select {
+	case n.events <- note:
+		return nil
+	case <-n.s.ctx.Done():
+		return n.s.ctx.Err()
+	}
+}
+
+// latest records an update made at time t to the order or execution with the
+// given ID, and returns the time of the latest update. The IDs of the updates
+// made at the latest time are kept in seen.
+func latest(last time.Time, seen map[int]bool, t time.Time, id int) time.Time {
+	if t.After(last) {
+		for k := range seen {
+			delete(seen, k)
+		}
+		last = t
+	}
+	if t.Equal(last) {
+		seen[id] = true
+	}
+	return last
+}
+
+// undelivered determines whether an update made at time t to the order or execution
+// with the given ID had not been delivered, given the time of the latest update
+// delivered, and the IDs of the updates delivered at that time.
+func undelivered(t time.Time, id int, last time.Time, seen map[int]bool) bool {
+	return t.After(last) || (t.Equal(last) && !seen[id])
+}
+
+// copyIDs returns a copy of a set of IDs
+func copyIDs(ids map[int]bool) map[int]bool {
+	c := make(map[int]bool, len(ids))
+	for k := range ids {
+		c[k] = true
+	}
+	return c
+}
+
+// Notifications returns the channel on which notifications are delivered. The
+// channel is closed once the stream stops.
+func (n *NotificationStream) Notifications() <-chan Notification {
+	return n.events
+}
+
+// Close stops the stream. The notification channel is closed once the stream has stopped.
+func (n *NotificationStream) Close() error {
+	n.s.close()
+	return nil
+}
+
+// Err returns the error that stopped the stream. It is nil while the stream is
+// running, and if the stream was stopped by Close or its context.
+func (n *NotificationStream) Err() error {
+	return n.s.error()
+}
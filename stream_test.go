@@ -0,0 +1,350 @@
+package qapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// quoteStreamServer is an API server whose quotes endpoint hands out a streaming
+// port. Every streaming connection is sent one quote, whose Symbol is the list of
+// symbol IDs the connection was opened for.
+type quoteStreamServer struct {
+	api *httptest.Server
+	ws  *httptest.Server
+
+	mu      sync.Mutex
+	ids     []string    // IDs requested by each successful call to the quotes endpoint
+	calls   []time.Time // time of every call to the quotes endpoint
+	fail    int         // number of calls to fail with HTTP 503, after the first
+	conns   int
+	drop    map[int]bool // connections to drop after sending their quote
+	garbage bool         // send a message that can't be decoded instead of the quote
+}
+
+func newQuoteStreamServer(t *testing.T) *quoteStreamServer {
+	s := &quoteStreamServer{drop: map[int]bool{}}
+
+	s.ws = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := upgrade(t, w, r)
+		if ws == nil {
+			return
+		}
+		defer ws.conn.Close()
+
+		if _, _, token, err := ws.readFrame(); err != nil || string(token) != "access" {
+			t.Errorf("stream authentication = %q %v, want access", token, err)
+			return
+		}
+		ws.conn.Write(serverFrame(true, wsText, []byte(`{"success":true}`)))
+
+		s.mu.Lock()
+		s.conns++
+		n := s.conns
+		ids := s.ids[len(s.ids)-1]
+		drop := s.drop[n]
+		garbage := s.garbage
+		s.mu.Unlock()
+
+		msg, _ := json.Marshal(map[string][]Quote{"quotes": {{Symbol: ids}}})
+		if garbage {
+			msg = []byte("{")
+		}
+		ws.conn.Write(serverFrame(true, wsText, msg))
+		if drop {
+			return
+		}
+
+		// Wait for the client to go away
+		for {
+			if _, _, _, err := ws.readFrame(); err != nil {
+				return
+			}
+		}
+	}))
+
+	s.api = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/markets/quotes" || r.URL.Query().Get("stream") != "true" {
+			http.NotFound(w, r)
+			return
+		}
+
+		s.mu.Lock()
+		s.calls = append(s.calls, time.Now())
+		fail := len(s.calls) > 1 && s.fail > 0
+		if fail {
+			s.fail--
+		} else {
+			s.ids = append(s.ids, r.URL.Query().Get("ids"))
+		}
+		s.mu.Unlock()
+
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"code":1000,"message":"Service unavailable"}`)
+			return
+		}
+
+		u, _ := url.Parse(s.ws.URL)
+		port, _ := strconv.Atoi(u.Port())
+		json.NewEncoder(w).Encode(map[string]int{"streamPort": port})
+	}))
+	return s
+}
+
+func (s *quoteStreamServer) Close() {
+	s.api.Close()
+	s.ws.Close()
+}
+
+// nextQuote waits for the next quote of a stream
+func nextQuote(t *testing.T, q *QuoteStream) Quote {
+	t.Helper()
+	select {
+	case quote, ok := <-q.Quotes():
+		if !ok {
+			t.Fatalf("quote channel closed: %v", q.Err())
+		}
+		return quote
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a quote")
+	}
+	return Quote{}
+}
+
+func TestQuoteStreamReconnectsAndResubscribes(t *testing.T) {
+	srv := newQuoteStreamServer(t)
+	defer srv.Close()
+	srv.drop[1] = true
+
+	c, err := NewClient("", true,
+		WithCredentials(LoginCredentials{AccessToken: "access", TokenType: "Bearer", ApiServer: srv.api.URL + "/"}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	q, err := c.StreamQuotes(context.Background(), 8049)
+	if err != nil {
+		t.Fatalf("StreamQuotes: %v", err)
+	}
+
+	// The first connection is dropped, and the stream reconnects by itself
+	if got := nextQuote(t, q).Symbol; got != "8049" {
+		t.Errorf("first connection subscribed to %q, want 8049", got)
+	}
+	if got := nextQuote(t, q).Symbol; got != "8049" {
+		t.Errorf("reconnection subscribed to %q, want 8049", got)
+	}
+
+	q.Subscribe(9291)
+	if got := nextQuote(t, q).Symbol; got != "8049,9291" {
+		t.Errorf("after Subscribe, subscribed to %q, want 8049,9291", got)
+	}
+
+	q.Unsubscribe(8049)
+	if got := nextQuote(t, q).Symbol; got != "9291" {
+		t.Errorf("after Unsubscribe, subscribed to %q, want 9291", got)
+	}
+
+	q.Close()
+	if _, ok := <-q.Quotes(); ok {
+		t.Error("quote channel still open after Close")
+	}
+	if err := q.Err(); err != nil {
+		t.Errorf("Err after Close = %v, want nil", err)
+	}
+}
+
+func TestQuoteStreamBacksOffWhenReconnectingFails(t *testing.T) {
+	srv := newQuoteStreamServer(t)
+	defer srv.Close()
+	srv.drop[1] = true
+	srv.fail = 3
+
+	const backoff = 100 * time.Millisecond
+	c, err := NewClient("", true,
+		WithCredentials(LoginCredentials{AccessToken: "access", TokenType: "Bearer", ApiServer: srv.api.URL + "/"}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 1, MinBackoff: backoff, MaxBackoff: backoff}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	q, err := c.StreamQuotes(context.Background(), 8049)
+	if err != nil {
+		t.Fatalf("StreamQuotes: %v", err)
+	}
+	defer q.Close()
+
+	// The first connection is dropped, and the stream port can't be looked up
+	// three times before the stream reconnects
+	nextQuote(t, q)
+	nextQuote(t, q)
+
+	srv.mu.Lock()
+	calls := srv.calls
+	srv.mu.Unlock()
+
+	if len(calls) != 5 {
+		t.Fatalf("quotes endpoint called %d times, want 5", len(calls))
+	}
+	for k := 2; k < len(calls); k++ {
+		// The backoff has up to half of its length taken off as jitter
+		if gap := calls[k].Sub(calls[k-1]); gap < backoff/2 {
+			t.Errorf("call %d made %v after the one before, want at least %v", k+1, gap, backoff/2)
+		}
+	}
+}
+
+func TestQuoteStreamStopsOnUndecodableMessage(t *testing.T) {
+	srv := newQuoteStreamServer(t)
+	defer srv.Close()
+	srv.garbage = true
+
+	c, err := NewClient("", true,
+		WithCredentials(LoginCredentials{AccessToken: "access", TokenType: "Bearer", ApiServer: srv.api.URL + "/"}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	q, err := c.StreamQuotes(context.Background(), 8049)
+	if err != nil {
+		t.Fatalf("StreamQuotes: %v", err)
+	}
+	defer q.Close()
+
+	select {
+	case quote, ok := <-q.Quotes():
+		if ok {
+			t.Fatalf("received quote %+v, want the channel closed", quote)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream to stop")
+	}
+
+	if q.Err() == nil {
+		t.Error("Err = nil, want the decoding error")
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.conns != 1 {
+		t.Errorf("stream connected %d times, want once", srv.conns)
+	}
+}
+
+func TestNotificationStreamRecoversMissedUpdates(t *testing.T) {
+	at := time.Now().Add(time.Minute).Round(time.Second)
+	order := func(id int, updated time.Time) Order {
+		return Order{ID: id, State: OrderStateExecuted, UpdateTime: &updated}
+	}
+
+	var mu sync.Mutex
+	conns := 0
+	ws := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := upgrade(t, w, r)
+		if ws == nil {
+			return
+		}
+		defer ws.conn.Close()
+
+		ws.readFrame()
+		ws.conn.Write(serverFrame(true, wsText, []byte(`{"success":true}`)))
+
+		mu.Lock()
+		conns++
+		n := conns
+		mu.Unlock()
+
+		// The first connection delivers an order and an execution, then drops
+		if n == 1 {
+			msg, _ := json.Marshal(Notification{
+				AccountNumber: "12345678",
+				Orders:        []Order{order(1, at)},
+				Executions:    []Execution{{ID: 10, Timestamp: at}},
+			})
+			ws.conn.Write(serverFrame(true, wsText, msg))
+			return
+		}
+
+		for {
+			if _, _, _, err := ws.readFrame(); err != nil {
+				return
+			}
+		}
+	}))
+	defer ws.Close()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/notifications":
+			u, _ := url.Parse(ws.URL)
+			port, _ := strconv.Atoi(u.Port())
+			json.NewEncoder(w).Encode(map[string]int{"streamPort": port})
+		case "/v1/accounts/12345678/orders":
+			// Order 2 was updated at the same time as order 1, and order 3 before it
+			json.NewEncoder(w).Encode(map[string][]Order{"orders": {
+				order(1, at), order(2, at), order(3, at.Add(-time.Second)), order(4, at.Add(time.Second)),
+			}})
+		case "/v1/accounts/12345678/executions":
+			json.NewEncoder(w).Encode(map[string][]Execution{"executions": {
+				{ID: 10, Timestamp: at}, {ID: 11, Timestamp: at},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	c, err := NewClient("", true,
+		WithCredentials(LoginCredentials{AccessToken: "access", TokenType: "Bearer", ApiServer: api.URL + "/"}),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	n, err := c.StreamNotifications(context.Background(), "12345678")
+	if err != nil {
+		t.Fatalf("StreamNotifications: %v", err)
+	}
+	defer n.Close()
+
+	next := func() Notification {
+		t.Helper()
+		select {
+		case note, ok := <-n.Notifications():
+			if !ok {
+				t.Fatalf("notification channel closed: %v", n.Err())
+			}
+			return note
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for a notification")
+		}
+		return Notification{}
+	}
+
+	if note := next(); note.Recovered || len(note.Orders) != 1 || len(note.Executions) != 1 {
+		t.Errorf("first notification = %+v, want order 1 and execution 10", note)
+	}
+
+	// Only the updates that weren't delivered are recovered
+	note := next()
+	var orders, execs []int
+	for _, o := range note.Orders {
+		orders = append(orders, o.ID)
+	}
+	for _, e := range note.Executions {
+		execs = append(execs, e.ID)
+	}
+	if !note.Recovered || fmt.Sprint(orders) != "[2 4]" || fmt.Sprint(execs) != "[11]" {
+		t.Errorf("recovered notification = orders %v, executions %v, recovered %v; want orders [2 4], executions [11], recovered true",
+			orders, execs, note.Recovered)
+	}
+}
@@ -0,0 +1,124 @@
+package qapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// TokenStore persists login credentials between runs of a program. Questrade
+// rotates the refresh token on every login, so the replacement token must be
+// saved before the old one is forgotten - otherwise a crash will require a new
+// manual authorization on the Questrade website.
+//
+// Load returns zero-value credentials and a nil error if nothing has been
+// stored yet. Implementations must be safe for concurrent use.
+type TokenStore interface {
+	Load() (LoginCredentials, error)
+	Save(LoginCredentials) error
+}
+
+// FileTokenStore is a TokenStore that keeps credentials in a JSON file. The file
+// is only readable by its owner, and is replaced atomically on every save so
+// that a crash can never leave a partially written token behind.
+type FileTokenStore struct {
+	Path string
+	mu   sync.Mutex
+}
+
+// NewFileTokenStore returns a TokenStore backed by the file at path.
+func NewFileTokenStore(path string) *FileTokenStore {
+	return &FileTokenStore{Path: path}
+}
+
+// Load reads the credentials from the file. A missing file is not an error.
+func (f *FileTokenStore) Load() (LoginCredentials, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var creds LoginCredentials
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return creds, nil
+	}
+	if err != nil {
+		return creds, err
+	}
+
+	err = json.Unmarshal(data, &creds)
+	return creds, err
+}
+
+// Save writes the credentials to a temporary file in the same directory, then
+// renames it over the existing file.
+func (f *FileTokenStore) Save(creds LoginCredentials) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(f.Path, data, 0600)
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as path,
+// then renames it over the existing file, so that a crash can never leave a
+// partially written file behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	// Clean up the temporary file if anything goes wrong before the rename
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// MemoryTokenStore is a TokenStore that keeps credentials in memory. It is
+// mostly useful for tests.
+type MemoryTokenStore struct {
+	creds LoginCredentials
+	mu    sync.Mutex
+}
+
+// NewMemoryTokenStore returns a TokenStore holding the given credentials.
+func NewMemoryTokenStore(creds LoginCredentials) *MemoryTokenStore {
+	return &MemoryTokenStore{creds: creds}
+}
+
+// Load returns the stored credentials.
+func (m *MemoryTokenStore) Load() (LoginCredentials, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.creds, nil
+}
+
+// Save replaces the stored credentials.
+func (m *MemoryTokenStore) Save(creds LoginCredentials) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.creds = creds
+	return nil
+}
@@ -0,0 +1,35 @@
+package qapi_test
+
+import (
+	"testing"
+
+	"github.com/alexurquhart/qapi"
+	"github.com/alexurquhart/qapi/qapitest"
+)
+
+func TestTokenStoreGivenTokenReplacesStored(t *testing.T) {
+	srv := qapitest.NewServer()
+	defer srv.Close()
+
+	store := qapi.NewMemoryTokenStore(qapi.LoginCredentials{RefreshToken: "stale"})
+	c, err := srv.NewClient(qapi.WithTokenStore(store))
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+
+	stored, _ := store.Load()
+	if stored.RefreshToken != srv.RefreshToken() {
+		t.Errorf("stored refresh token = %q, want %q", stored.RefreshToken, srv.RefreshToken())
+	}
+
+	// Another client sharing the store rotates the token, so later refreshes
+	// must read it from the store
+	if _, err := qapi.NewClientFromStore(store, true, qapi.WithLoginURL(srv.LoginURL())); err != nil {
+		t.Fatalf("NewClientFromStore: %v", err)
+	}
+	srv.ExpireSessions()
+
+	if _, err := c.GetServerTime(); err != nil {
+		t.Errorf("GetServerTime after rotation by another client: %v", err)
+	}
+}
@@ -0,0 +1,23 @@
+package qapi
+
+import (
+	"context"
+	"time"
+)
+
+// Trader is the part of the API that trading strategies use to place orders and
+// follow their account. It is implemented by Client, and by the paper trading
+// simulator in the sim package, so that a strategy written against Trader can be
+// run live or simulated without changes.
+type Trader interface {
+	GetQuotesContext(ctx context.Context, ids ...int) ([]Quote, error)
+	GetBalancesContext(ctx context.Context, number string) (AccountBalances, error)
+	GetPositionsContext(ctx context.Context, number string) ([]Position, error)
+	GetExecutionsContext(ctx context.Context, number string, start time.Time, end time.Time) ([]Execution, error)
+	GetOrdersContext(ctx context.Context, number string, start time.Time, end time.Time, state OrderStateFilter) ([]Order, error)
+	GetOrdersByIDContext(ctx context.Context, number string, orderIds ...int) ([]Order, error)
+	PlaceOrderContext(ctx context.Context, req OrderRequest) ([]Order, error)
+	DeleteOrderContext(ctx context.Context, acctNum string, orderID int) error
+}
+
+var _ Trader = (*Client)(nil)
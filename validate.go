@@ -0,0 +1,113 @@
+package qapi
+
+// Validate checks that the combination of fields in the order request is one that
+// Questrade will accept. Prices are not checked against the symbol's tick size -
+// see ValidateTicks. Returns an OrderValidationError describing the first problem found.
+func (r OrderRequest) Validate() error {
+	switch {
+	case r.AccountID == "":
+		return OrderValidationError{"AccountID", "account number is required"}
+	case r.SymbolID <= 0:
+		return OrderValidationError{"SymbolID", "symbol ID is required"}
+	case r.Quantity <= 0:
+		return OrderValidationError{"Quantity", "quantity must be positive"}
+	case r.IcebergQuantity < 0 || r.IcebergQuantity > r.Quantity:
+		return OrderValidationError{"IcebergQuantity", "iceberg quantity must be between zero and the order quantity"}
+	case !r.Action.Valid():
+		return OrderValidationError{"Action", "unknown order side " + string(r.Action)}
+	case !r.OrderType.Valid():
+		return OrderValidationError{"OrderType", "unknown order type " + string(r.OrderType)}
+	case !r.TimeInForce.Valid():
+		return OrderValidationError{"TimeInForce", "unknown time in force " + string(r.TimeInForce)}
+	case r.LimitPrice.Sign() < 0:
+		return OrderValidationError{"LimitPrice", "price can't be negative"}
+	case r.StopPrice.Sign() < 0:
+		return OrderValidationError{"StopPrice", "price can't be negative"}
+	}
+
+	needLimit, needStop := false, false
+	switch r.OrderType {
+	case OrderTypeLimit, OrderTypeLimitOnOpen, OrderTypeLimitOnClose:
+		needLimit = true
+	case OrderTypeStop, OrderTypeTrailStopInPercentage, OrderTypeTrailStopInDollar:
+		needStop = true
+	case OrderTypeStopLimit, OrderTypeTrailStopLimitInPercentage, OrderTypeTrailStopLimitInDollar:
+		needLimit, needStop = true, true
+	}
+
+	switch {
+	case needLimit && r.LimitPrice.IsZero():
+		return OrderValidationError{"LimitPrice", "required for " + string(r.OrderType) + " orders"}
+	case !needLimit && !r.LimitPrice.IsZero():
+		return OrderValidationError{"LimitPrice", "not allowed for " + string(r.OrderType) + " orders"}
+	case needStop && r.StopPrice.IsZero():
+		return OrderValidationError{"StopPrice", "required for " + string(r.OrderType) + " orders"}
+	case !needStop && !r.StopPrice.IsZero():
+		return OrderValidationError{"StopPrice", "not allowed for " + string(r.OrderType) + " orders"}
+	}
+
+	if r.TimeInForce == TimeInForceGoodTillDate && r.GtdDate == nil {
+		return OrderValidationError{"GtdDate", "required for GoodTillDate orders"}
+	}
+	if r.TimeInForce != TimeInForceGoodTillDate && r.GtdDate != nil {
+		return OrderValidationError{"GtdDate", "only allowed for GoodTillDate orders"}
+	}
+
+	return nil
+}
+
+// ValidateTicks checks that the order's prices are multiples of the minimum tick
+// size of the symbol at those prices. Trailing stop prices are offsets rather than
+// prices, so they are not checked.
+func (r OrderRequest) ValidateTicks(sym Symbol) error {
+	switch r.OrderType {
+	case OrderTypeTrailStopInPercentage, OrderTypeTrailStopInDollar,
+		OrderTypeTrailStopLimitInPercentage, OrderTypeTrailStopLimitInDollar:
+		return nil
+	}
+
+	if !sym.onTick(r.LimitPrice) {
+		return OrderValidationError{"LimitPrice", "price is not a multiple of the minimum tick"}
+	}
+	if !sym.onTick(r.StopPrice) {
+		return OrderValidationError{"StopPrice", "price is not a multiple of the minimum tick"}
+	}
+	return nil
+}
+
+// RoundPrices rounds the order's prices to the nearest multiple of the symbol's
+// minimum tick size.
+func (r *OrderRequest) RoundPrices(sym Symbol) {
+	r.LimitPrice = sym.RoundToTick(r.LimitPrice)
+	r.StopPrice = sym.RoundToTick(r.StopPrice)
+}
+
+// MinTick returns the minimum tick size of the symbol at a given price, which is
+// the tick of the highest pivot that the price is at or above. Returns zero if
+// the symbol has no tick data.
+func (s Symbol) MinTick(price Money) Money {
+	var tick, pivot Money
+	found := false
+	for _, t := range s.MinTicks {
+		if price.Cmp(t.Pivot) >= 0 && (!found || t.Pivot.Cmp(pivot) > 0) {
+			tick, pivot, found = t.MinTick, t.Pivot, true
+		}
+	}
+	return tick
+}
+
+// RoundToTick rounds a price to the nearest multiple of the symbol's minimum tick
+// size at that price.
+func (s Symbol) RoundToTick(price Money) Money {
+	tick := s.MinTick(price)
+	if tick.Sign() <= 0 {
+		return price
+	}
+	return price.RoundTo(tick)
+}
+
+// onTick determines whether a price is a multiple of the minimum tick size
+func (s Symbol) onTick(price Money) bool {
+	tick := s.MinTick(price)
+	return tick.Sign() <= 0 || price.Micros()%tick.Micros() == 0
+}
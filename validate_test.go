@@ -0,0 +1,159 @@
+package qapi_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alexurquhart/qapi"
+)
+
+// limitOrder returns a valid limit order request
+func limitOrder() qapi.OrderRequest {
+	return qapi.OrderRequest{
+		AccountID:   "12345678",
+		SymbolID:    8049,
+		Quantity:    100,
+		OrderType:   qapi.OrderTypeLimit,
+		LimitPrice:  qapi.MustParseMoney("0.50"),
+		TimeInForce: qapi.TimeInForceDay,
+		Action:      qapi.SideBuy,
+	}
+}
+
+// invalidField returns the field of an OrderValidationError, or "" if err is nil
+func invalidField(t *testing.T, err error) string {
+	t.Helper()
+	if err == nil {
+		return ""
+	}
+	var ve qapi.OrderValidationError
+	if !errors.As(err, &ve) {
+		t.Fatalf("error %v is a %T, want an OrderValidationError", err, err)
+	}
+	return ve.Field
+}
+
+func TestValidate(t *testing.T) {
+	gtd := time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		change func(r *qapi.OrderRequest)
+		field  string
+	}{
+		{"valid limit order", func(r *qapi.OrderRequest) {}, ""},
+		{"no account", func(r *qapi.OrderRequest) { r.AccountID = "" }, "AccountID"},
+		{"no symbol", func(r *qapi.OrderRequest) { r.SymbolID = 0 }, "SymbolID"},
+		{"zero quantity", func(r *qapi.OrderRequest) { r.Quantity = 0 }, "Quantity"},
+		{"iceberg quantity above quantity", func(r *qapi.OrderRequest) { r.IcebergQuantity = 101 }, "IcebergQuantity"},
+		{"iceberg quantity equal to quantity", func(r *qapi.OrderRequest) { r.IcebergQuantity = 100 }, ""},
+		{"unknown side", func(r *qapi.OrderRequest) { r.Action = "Hold" }, "Action"},
+		{"unknown order type", func(r *qapi.OrderRequest) { r.OrderType = "Best" }, "OrderType"},
+		{"unknown time in force", func(r *qapi.OrderRequest) { r.TimeInForce = "Forever" }, "TimeInForce"},
+		{"negative limit price", func(r *qapi.OrderRequest) { r.LimitPrice = qapi.MustParseMoney("-1") }, "LimitPrice"},
+
+		{"limit order without a limit price", func(r *qapi.OrderRequest) { r.LimitPrice = qapi.Money{} }, "LimitPrice"},
+		{"limit order with a stop price", func(r *qapi.OrderRequest) { r.StopPrice = qapi.MustParseMoney("0.45") }, "StopPrice"},
+		{"market order", func(r *qapi.OrderRequest) {
+			r.OrderType, r.LimitPrice = qapi.OrderTypeMarket, qapi.Money{}
+		}, ""},
+		{"market order with a limit price", func(r *qapi.OrderRequest) { r.OrderType = qapi.OrderTypeMarket }, "LimitPrice"},
+		{"stop order", func(r *qapi.OrderRequest) {
+			r.OrderType, r.LimitPrice, r.StopPrice = qapi.OrderTypeStop, qapi.Money{}, qapi.MustParseMoney("0.45")
+		}, ""},
+		{"stop order without a stop price", func(r *qapi.OrderRequest) {
+			r.OrderType, r.LimitPrice = qapi.OrderTypeStop, qapi.Money{}
+		}, "StopPrice"},
+		{"stop limit order", func(r *qapi.OrderRequest) {
+			r.OrderType, r.StopPrice = qapi.OrderTypeStopLimit, qapi.MustParseMoney("0.45")
+		}, ""},
+		{"stop limit order without a limit price", func(r *qapi.OrderRequest) {
+			r.OrderType, r.LimitPrice, r.StopPrice = qapi.OrderTypeStopLimit, qapi.Money{}, qapi.MustParseMoney("0.45")
+		}, "LimitPrice"},
+		{"trailing stop limit order without a stop price", func(r *qapi.OrderRequest) {
+			r.OrderType = qapi.OrderTypeTrailStopLimitInDollar
+		}, "StopPrice"},
+		{"limit on close order", func(r *qapi.OrderRequest) { r.OrderType = qapi.OrderTypeLimitOnClose }, ""},
+
+		{"good till date order", func(r *qapi.OrderRequest) {
+			r.TimeInForce, r.GtdDate = qapi.TimeInForceGoodTillDate, &gtd
+		}, ""},
+		{"good till date order without a date", func(r *qapi.OrderRequest) {
+			r.TimeInForce = qapi.TimeInForceGoodTillDate
+		}, "GtdDate"},
+		{"day order with a date", func(r *qapi.OrderRequest) { r.GtdDate = &gtd }, "GtdDate"},
+	}
+
+	for _, tt := range tests {
+		r := limitOrder()
+		tt.change(&r)
+		if got := invalidField(t, r.Validate()); got != tt.field {
+			t.Errorf("%s: invalid field = %q, want %q", tt.name, got, tt.field)
+		}
+	}
+}
+
+func TestValidateTicks(t *testing.T) {
+	// Half a cent below $0.50, and a cent from then on
+	sym := qapi.Symbol{MinTicks: []qapi.MinTickData{
+		{Pivot: qapi.MustParseMoney("0.50"), MinTick: qapi.MustParseMoney("0.01")},
+		{Pivot: qapi.Money{}, MinTick: qapi.MustParseMoney("0.005")},
+	}}
+
+	tests := []struct {
+		orderType qapi.OrderType
+		limit     string
+		stop      string
+		field     string
+	}{
+		{qapi.OrderTypeLimit, "0.495", "", ""},
+		{qapi.OrderTypeLimit, "0.497", "", "LimitPrice"},
+		{qapi.OrderTypeLimit, "0.50", "", ""},
+		{qapi.OrderTypeLimit, "0.505", "", "LimitPrice"},
+		{qapi.OrderTypeLimit, "12.34", "", ""},
+		{qapi.OrderTypeStopLimit, "0.51", "0.485", ""},
+		{qapi.OrderTypeStopLimit, "0.51", "0.487", "StopPrice"},
+		{qapi.OrderTypeTrailStopLimitInDollar, "0.51", "0.001", ""},
+	}
+
+	for _, tt := range tests {
+		r := limitOrder()
+		r.OrderType = tt.orderType
+		r.LimitPrice = qapi.MustParseMoney(tt.limit)
+		if tt.stop != "" {
+			r.StopPrice = qapi.MustParseMoney(tt.stop)
+		}
+		if got := invalidField(t, r.ValidateTicks(sym)); got != tt.field {
+			t.Errorf("%s at %s/%s: invalid field = %q, want %q", tt.orderType, tt.limit, tt.stop, got, tt.field)
+		}
+	}
+
+	// Without tick data, any price is accepted
+	r := limitOrder()
+	r.LimitPrice = qapi.MustParseMoney("0.123456")
+	if err := r.ValidateTicks(qapi.Symbol{}); err != nil {
+		t.Errorf("ValidateTicks without tick data = %v, want nil", err)
+	}
+
+	r.RoundPrices(sym)
+	if got := r.LimitPrice.String(); got != "0.125" {
+		t.Errorf("RoundPrices = %s, want 0.125", got)
+	}
+}
+
+func TestOrderValidationError(t *testing.T) {
+	r := limitOrder()
+	r.Quantity = -5
+
+	err := r.Validate()
+	ve, ok := err.(qapi.OrderValidationError)
+	if !ok {
+		t.Fatalf("Validate = %T %v, want an OrderValidationError", err, err)
+	}
+	if ve.Field != "Quantity" || ve.Message != "quantity must be positive" {
+		t.Errorf("error = %+v, want the Quantity field and its problem", ve)
+	}
+	if got, want := ve.Error(), "Error: Invalid order Quantity: quantity must be positive"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,303 @@
+package qapi
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// WebSocket opcodes
+// Ref: https://tools.ietf.org/html/rfc6455#section-5.2
+const (
+	wsContinuation = 0x0
+	wsText         = 0x1
+	wsBinary       = 0x2
+	wsClose        = 0x8
+	wsPing         = 0x9
+	wsPong         = 0xa
+)
+
+// wsGUID is appended to the handshake key to compute the accept key
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// wsMaxMessageSize limits the size of messages read from the server
+const wsMaxMessageSize = 16 << 20
+
+// wsWriteTimeout limits how long a frame can take to be written
+const wsWriteTimeout = 10 * time.Second
+
+// errWSClosed is returned when the server closes the WebSocket connection
+var errWSClosed = errors.New("Error: WebSocket connection closed by server")
+
+// wsConn is a minimal client side WebSocket connection, sufficient for the
+// Questrade streaming endpoints. It is safe to write from multiple goroutines,
+// but only one goroutine may read.
+type wsConn struct {
+	conn        net.Conn
+	br          *bufio.Reader
+	readTimeout time.Duration
+	wmu         sync.Mutex
+}
+
+// dialWebSocket opens a WebSocket connection to a ws:// or wss:// URL.
+func dialWebSocket(ctx context.Context, rawurl string, tlsConfig *tls.Config) (*wsConn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the handshake can't outlive the context
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if u.Scheme == "wss" {
+		cfg := &tls.Config{}
+		if tlsConfig != nil {
+			cfg = tlsConfig.Clone()
+		}
+		if cfg.ServerName == "" {
+			cfg.ServerName = u.Hostname()
+		}
+
+		tlsConn := tls.Client(conn, cfg)
+		if err := tlsConn.Handshake(); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+	}
+
+	ws := &wsConn{
+		conn: conn,
+		br:   bufio.NewReader(conn),
+	}
+
+	if err := ws.handshake(u); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	conn.SetDeadline(time.Time{})
+	return ws, nil
+}
+
+// handshake upgrades the connection to the WebSocket protocol
+// Ref: https://tools.ietf.org/html/rfc6455#section-4.1
+func (w *wsConn) handshake(u *url.URL) error {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+
+	path := u.RequestURI()
+	req, err := http.NewRequest("GET", "http://"+u.Host+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := req.Write(w.conn); err != nil {
+		return err
+	}
+
+	res, err := http.ReadResponse(w.br, req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		return fmt.Errorf("Error: WebSocket handshake failed [%s]", res.Status)
+	}
+
+	h := sha1.Sum([]byte(key + wsGUID))
+	if res.Header.Get("Sec-WebSocket-Accept") != base64.StdEncoding.EncodeToString(h[:]) {
+		return errors.New("Error: WebSocket handshake failed - invalid accept key")
+	}
+
+	return nil
+}
+
+// ReadMessage reads the next text or binary message from the connection. Ping
+// frames are answered automatically. If a read timeout is set, an error is
+// returned when no frame at all arrives within that time.
+func (w *wsConn) ReadMessage() (int, []byte, error) {
+	var op int
+	var msg []byte
+
+	for {
+		fin, frameOp, payload, err := w.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch frameOp {
+		case wsPing:
+			if err := w.writeFrame(wsPong, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case wsPong:
+			continue
+		case wsClose:
+			// Echo the status code back before giving up on the connection
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			w.writeFrame(wsClose, payload)
+			return 0, nil, errWSClosed
+		case wsContinuation:
+			msg = append(msg, payload...)
+		default:
+			op = frameOp
+			msg = payload
+		}
+
+		if len(msg) > wsMaxMessageSize {
+			return 0, nil, errors.New("Error: WebSocket message too large")
+		}
+
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+// readFrame reads a single frame from the connection
+// Ref: https://tools.ietf.org/html/rfc6455#section-5.2
+func (w *wsConn) readFrame() (bool, int, []byte, error) {
+	if w.readTimeout > 0 {
+		w.conn.SetReadDeadline(time.Now().Add(w.readTimeout))
+	}
+
+	var h [2]byte
+	if _, err := io.ReadFull(w.br, h[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := h[0]&0x80 != 0
+	op := int(h[0] & 0x0f)
+	masked := h[1]&0x80 != 0
+
+	n := uint64(h[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(w.br, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if n > wsMaxMessageSize {
+		return false, 0, nil, errors.New("Error: WebSocket frame too large")
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(w.br, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(w.br, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, op, payload, nil
+}
+
+// WriteMessage sends a text or binary message as a single frame
+func (w *wsConn) WriteMessage(op int, data []byte) error {
+	return w.writeFrame(op, data)
+}
+
+// Ping sends a ping frame, which the server should answer with a pong
+func (w *wsConn) Ping() error {
+	return w.writeFrame(wsPing, nil)
+}
+
+// writeFrame sends a single, masked frame. Frames sent by clients must always be masked.
+func (w *wsConn) writeFrame(op int, payload []byte) error {
+	frame := []byte{0x80 | byte(op)}
+
+	n := len(payload)
+	switch {
+	case n < 126:
+		frame = append(frame, 0x80|byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 0x80|126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, 0x80|127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+
+	var mask [4]byte
+	if _, err := rand.Read(mask[:]); err != nil {
+		return err
+	}
+	frame = append(frame, mask[:]...)
+
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	w.wmu.Lock()
+	defer w.wmu.Unlock()
+
+	w.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, err := w.conn.Write(frame)
+	return err
+}
+
+// Close sends a close frame and closes the underlying connection
+func (w *wsConn) Close() error {
+	w.writeFrame(wsClose, []byte{0x03, 0xe8})
+	return w.conn.Close()
+}
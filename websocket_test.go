@@ -0,0 +1,157 @@
+package qapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// upgrade performs the server side of the WebSocket handshake. The returned
+// connection's readFrame reads the client's masked frames, and serverFrame
+// encodes frames to write to it.
+func upgrade(t *testing.T, w http.ResponseWriter, r *http.Request) *wsConn {
+	h := sha1.Sum([]byte(r.Header.Get("Sec-WebSocket-Key") + wsGUID))
+
+	conn, brw, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Errorf("Hijack: %v", err)
+		return nil
+	}
+
+	fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: %s\r\n\r\n", base64.StdEncoding.EncodeToString(h[:]))
+	brw.Flush()
+
+	return &wsConn{conn: conn, br: brw.Reader}
+}
+
+// serverFrame encodes an unmasked frame, as sent by a server
+func serverFrame(fin bool, op int, payload []byte) []byte {
+	b0 := byte(op)
+	if fin {
+		b0 |= 0x80
+	}
+	frame := []byte{b0}
+
+	n := len(payload)
+	switch {
+	case n < 126:
+		frame = append(frame, byte(n))
+	case n <= 0xffff:
+		frame = append(frame, 126, 0, 0)
+		binary.BigEndian.PutUint16(frame[2:], uint16(n))
+	default:
+		frame = append(frame, 127, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(frame[2:], uint64(n))
+	}
+	return append(frame, payload...)
+}
+
+// wsURL returns the WebSocket URL of a test server
+func wsURL(srv *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + "/"
+}
+
+func TestWebSocketFragmentsAndPings(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), 70000)
+	received := make(chan [][]byte, 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws := upgrade(t, w, r)
+		if ws == nil {
+			return
+		}
+		defer ws.conn.Close()
+
+		// A fragmented message with a ping between its fragments, then a message
+		// that needs a 64-bit length
+		ws.conn.Write(serverFrame(true, wsPing, []byte("ping-1")))
+		ws.conn.Write(serverFrame(false, wsText, []byte("hel")))
+		ws.conn.Write(serverFrame(true, wsPing, []byte("ping-2")))
+		ws.conn.Write(serverFrame(false, wsContinuation, bytes.Repeat([]byte("l"), 200)))
+		ws.conn.Write(serverFrame(true, wsContinuation, []byte("o")))
+		ws.conn.Write(serverFrame(true, wsBinary, long))
+
+		// Two pongs, the client's message, then the echoed close frame
+		var payloads [][]byte
+		for i := 0; i < 3; i++ {
+			_, _, payload, err := ws.readFrame()
+			if err != nil {
+				t.Errorf("readFrame: %v", err)
+				break
+			}
+			payloads = append(payloads, payload)
+		}
+
+		ws.conn.Write(serverFrame(true, wsClose, []byte{0x03, 0xe8, 'b', 'y', 'e'}))
+		if _, op, payload, err := ws.readFrame(); err != nil || op != wsClose || !bytes.Equal(payload, []byte{0x03, 0xe8}) {
+			t.Errorf("close reply = %d %v %v, want %d [3 232]", op, payload, err, wsClose)
+		}
+		received <- payloads
+	}))
+	defer srv.Close()
+
+	ws, err := dialWebSocket(context.Background(), wsURL(srv), nil)
+	if err != nil {
+		t.Fatalf("dialWebSocket: %v", err)
+	}
+	defer ws.conn.Close()
+
+	op, msg, err := ws.ReadMessage()
+	want := "hel" + strings.Repeat("l", 200) + "o"
+	if err != nil || op != wsText || string(msg) != want {
+		t.Fatalf("ReadMessage = %d %q %v, want %d %q", op, msg, err, wsText, want)
+	}
+
+	op, msg, err = ws.ReadMessage()
+	if err != nil || op != wsBinary || !bytes.Equal(msg, long) {
+		t.Fatalf("ReadMessage = %d (%d bytes) %v, want %d (%d bytes)", op, len(msg), err, wsBinary, len(long))
+	}
+
+	sent := bytes.Repeat([]byte("y"), 300)
+	if err := ws.WriteMessage(wsText, sent); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+
+	if _, _, err := ws.ReadMessage(); err != errWSClosed {
+		t.Errorf("ReadMessage after close frame = %v, want %v", err, errWSClosed)
+	}
+
+	payloads := <-received
+	if len(payloads) != 3 {
+		t.Fatalf("server received %d frames, want 3", len(payloads))
+	}
+	if string(payloads[0]) != "ping-1" || string(payloads[1]) != "ping-2" {
+		t.Errorf("pong payloads = %q %q, want ping-1 ping-2", payloads[0], payloads[1])
+	}
+	if !bytes.Equal(payloads[2], sent) {
+		t.Errorf("server received %q, want %q", payloads[2], sent)
+	}
+}
+
+func TestWebSocketHandshakeRejectsBadAcceptKey(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Upgrade", "websocket")
+		w.Header().Set("Connection", "Upgrade")
+		w.Header().Set("Sec-WebSocket-Accept", "invalid")
+		w.WriteHeader(http.StatusSwitchingProtocols)
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := dialWebSocket(ctx, wsURL(srv), nil); err == nil || !strings.Contains(err.Error(), "accept key") {
+		t.Errorf("dialWebSocket = %v, want an invalid accept key error", err)
+	}
+}